@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bytes"
+	"container/list"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// AssetCacheConfig limita o cache em memória de arquivos estáticos e suas versões comprimidas
+type AssetCacheConfig struct {
+	MaxBytes     int64 `json:"max_bytes"`      // Tamanho total do cache. Padrão: 64 MB; negativo desativa
+	MaxFileBytes int64 `json:"max_file_bytes"` // Arquivos maiores não são cacheados. Padrão: 8 MB
+}
+
+// assetEntry é o conteúdo de um arquivo em uma codificação ("" para o original)
+type assetEntry struct {
+	key  string
+	file string
+	data []byte
+}
+
+// assetCacheStore é um LRU limitado pela soma dos tamanhos dos conteúdos
+type assetCacheStore struct {
+	mu           sync.Mutex
+	maxBytes     int64
+	maxFileBytes int64
+	bytes        int64
+	order        *list.List // Mais recente na frente
+	entries      map[string]*list.Element
+
+	hits      int64
+	misses    int64
+	evictions int64
+}
+
+// Cache de arquivos estáticos, configurado em main
+var assetCache = newAssetCache(AssetCacheConfig{})
+
+func newAssetCache(cfg AssetCacheConfig) *assetCacheStore {
+	if cfg.MaxBytes == 0 {
+		cfg.MaxBytes = 64 << 20
+	}
+	if cfg.MaxFileBytes <= 0 {
+		cfg.MaxFileBytes = 8 << 20
+	}
+	return &assetCacheStore{
+		maxBytes:     cfg.MaxBytes,
+		maxFileBytes: cfg.MaxFileBytes,
+		order:        list.New(),
+		entries:      make(map[string]*list.Element),
+	}
+}
+
+func (c *assetCacheStore) enabled() bool {
+	return c.maxBytes > 0
+}
+
+// assetKey identifica uma versão do arquivo: caminho, mtime, tamanho e codificação
+func assetKey(file string, info os.FileInfo, encoding string) string {
+	return fmt.Sprintf("%s\x00%d\x00%d\x00%s", file, info.ModTime().UnixNano(), info.Size(), encoding)
+}
+
+// get retorna o conteúdo do arquivo na codificação pedida, lendo e comprimindo na primeira vez.
+// A chave vem do Stat do mesmo arquivo aberto para a leitura, para que uma versão nunca seja
+// guardada com o mtime/tamanho de outra; as informações retornadas correspondem ao conteúdo.
+func (c *assetCacheStore) get(file string, encoding string) ([]byte, os.FileInfo, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	if !info.Mode().IsRegular() || info.Size() > c.maxFileBytes {
+		return nil, nil, fmt.Errorf("'%s' não pode ser cacheado", file)
+	}
+
+	key := assetKey(file, info, encoding)
+	c.mu.Lock()
+	if elem, ok := c.entries[key]; ok {
+		c.order.MoveToFront(elem)
+		c.mu.Unlock()
+		atomic.AddInt64(&c.hits, 1)
+		return elem.Value.(*assetEntry).data, info, nil
+	}
+	c.mu.Unlock()
+	atomic.AddInt64(&c.misses, 1)
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Arquivo reescrito durante a leitura: serve o que foi lido, mas não guarda
+	after, err := f.Stat()
+	cacheable := err == nil && after.ModTime().Equal(info.ModTime()) && after.Size() == info.Size() && int64(len(data)) == info.Size()
+	if encoding != "" {
+		var buf bytes.Buffer
+		encoder := newEncoder(encoding, &buf)
+		if _, err := encoder.Write(data); err != nil {
+			return nil, nil, err
+		}
+		if err := encoder.Close(); err != nil {
+			return nil, nil, err
+		}
+		data = buf.Bytes()
+	}
+	if cacheable {
+		c.add(&assetEntry{key: key, file: file, data: data})
+	}
+	return data, info, nil
+}
+
+func (c *assetCacheStore) add(entry *assetEntry) {
+	size := int64(len(entry.data))
+	if size > c.maxBytes {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[entry.key]; ok {
+		return // Outra requisição já preencheu
+	}
+	c.entries[entry.key] = c.order.PushFront(entry)
+	c.bytes += size
+	for c.bytes > c.maxBytes {
+		c.removeElement(c.order.Back())
+		c.evictions++
+	}
+}
+
+// removeElement deve ser chamado com c.mu travado
+func (c *assetCacheStore) removeElement(elem *list.Element) {
+	entry := elem.Value.(*assetEntry)
+	c.order.Remove(elem)
+	delete(c.entries, entry.key)
+	c.bytes -= int64(len(entry.data))
+}
+
+// invalidate remove as versões de um arquivo (ou de tudo abaixo de um diretório removido)
+func (c *assetCacheStore) invalidate(file string) {
+	file = filepath.Clean(file)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for elem := c.order.Front(); elem != nil; {
+		next := elem.Next()
+		entryFile := elem.Value.(*assetEntry).file
+		if entryFile == file || strings.HasPrefix(entryFile, file+string(os.PathSeparator)) {
+			c.removeElement(elem)
+		}
+		elem = next
+	}
+}
+
+// assetCacheStats são as métricas expostas em /api/status
+type assetCacheStats struct {
+	Entries   int   `json:"entries"`
+	Bytes     int64 `json:"bytes"`
+	MaxBytes  int64 `json:"max_bytes"`
+	Hits      int64 `json:"hits"`
+	Misses    int64 `json:"misses"`
+	Evictions int64 `json:"evictions"`
+}
+
+func (c *assetCacheStore) stats() assetCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return assetCacheStats{
+		Entries:   len(c.entries),
+		Bytes:     c.bytes,
+		MaxBytes:  c.maxBytes,
+		Hits:      atomic.LoadInt64(&c.hits),
+		Misses:    atomic.LoadInt64(&c.misses),
+		Evictions: c.evictions,
+	}
+}
+
+// assetCacheMiddleware serve arquivos estáticos a partir do cache, já comprimidos na codificação
+// negociada. HTML (que recebe a injeção) e tipos desconhecidos seguem para o FileServer.
+func assetCacheMiddleware(serveDir string, compress bool, cfg CompressionConfig, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		urlPath := path.Clean("/" + r.URL.Path)
+		contentType := mime.TypeByExtension(path.Ext(urlPath))
+		if !assetCache.enabled() || (r.Method != http.MethodGet && r.Method != http.MethodHead) || strings.HasSuffix(r.URL.Path, "/") || contentType == "" || strings.HasPrefix(contentType, "text/html") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		file := filepath.Join(serveDir, filepath.FromSlash(urlPath))
+		info, err := os.Stat(file)
+		if err != nil || !info.Mode().IsRegular() || info.Size() > assetCache.maxFileBytes {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		encoding := ""
+		if compress && cfg.compressible(contentType) {
+			addVary(w.Header())
+			if info.Size() >= int64(cfg.MinSize) {
+				encoding = negotiateEncoding(r.Header.Get("Accept-Encoding"), cfg.Encodings)
+			}
+		}
+		data, info, err := assetCache.get(file, encoding)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", contentType)
+		if encoding != "" {
+			w.Header().Set("Content-Encoding", encoding)
+			if etag := w.Header().Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+				w.Header().Set("ETag", "W/"+etag)
+			}
+		}
+		http.ServeContent(w, r, urlPath, info.ModTime(), bytes.NewReader(data))
+	})
+}
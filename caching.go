@@ -0,0 +1,278 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Modos de cache
+const (
+	cacheModeDev     = "dev"     // Nada é cacheado pelo navegador (padrão)
+	cacheModePreview = "preview" // Cabeçalhos como em produção: ETag, 304 e Cache-Control por regra
+)
+
+// CacheRule define o Cache-Control das respostas cujo caminho corresponde a 'paths' (globs) ou 'regex'
+type CacheRule struct {
+	Paths        []string `json:"paths"`
+	Regex        string   `json:"regex"`
+	CacheControl string   `json:"cache_control"`
+
+	regex *regexp.Regexp
+}
+
+// Regras usadas no modo preview quando nenhuma é configurada
+var defaultCacheRules = []CacheRule{
+	{Paths: []string{"/**/", "/**/*.{html,htm}"}, CacheControl: "no-cache"},
+	{Regex: `[.-][0-9a-fA-F]{8,}\.[a-z0-9]+$`, CacheControl: "public, max-age=31536000, immutable"},
+	{Paths: []string{"/**"}, CacheControl: "public, max-age=0, must-revalidate"},
+}
+
+func (rule CacheRule) matches(urlPath string) bool {
+	if rule.regex != nil && rule.regex.MatchString(urlPath) {
+		return true
+	}
+	for _, pattern := range rule.Paths {
+		if matchGlob(pattern, urlPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// cachePolicy guarda o modo atual (alterável via API) e as regras
+type cachePolicy struct {
+	mode  atomic.Value // string
+	rules []CacheRule
+}
+
+// Política de cache global, configurada em main
+var caching = newCachePolicy()
+
+func newCachePolicy() *cachePolicy {
+	p := &cachePolicy{}
+	p.mode.Store(cacheModeDev)
+	return p
+}
+
+func validCacheMode(mode string) bool {
+	return mode == cacheModeDev || mode == cacheModePreview
+}
+
+func (p *cachePolicy) configure(mode string, rules []CacheRule) error {
+	if mode == "" {
+		mode = cacheModeDev
+	}
+	if !validCacheMode(mode) {
+		return fmt.Errorf("cache_mode inválido %q (use %s ou %s)", mode, cacheModeDev, cacheModePreview)
+	}
+	if len(rules) == 0 {
+		rules = defaultCacheRules
+	}
+	for i := range rules {
+		if rules[i].Regex == "" {
+			continue
+		}
+		re, err := regexp.Compile(rules[i].Regex)
+		if err != nil {
+			return fmt.Errorf("regra de cache %d: regex inválida: %v", i, err)
+		}
+		rules[i].regex = re
+	}
+	p.rules = rules
+	p.mode.Store(mode)
+	return nil
+}
+
+func (p *cachePolicy) current() string {
+	return p.mode.Load().(string)
+}
+
+// cacheControl retorna o Cache-Control da primeira regra que corresponde ao caminho
+func (p *cachePolicy) cacheControl(urlPath string) string {
+	for _, rule := range p.rules {
+		if rule.matches(urlPath) {
+			return rule.CacheControl
+		}
+	}
+	return ""
+}
+
+// cachingMiddleware aplica o modo de cache. Em dev, força no-store (comportamento original);
+// em preview, usa as regras sem sobrescrever um Cache-Control definido pelo handler (ex: proxy).
+func cachingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if caching.current() == cacheModeDev {
+			w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+			w.Header().Set("Pragma", "no-cache")
+			w.Header().Set("Expires", "0")
+			next.ServeHTTP(w, r)
+			return
+		}
+		cacheControl := caching.cacheControl(r.URL.Path)
+		hw := &hookWriter{ResponseWriter: w, before: func(status int) {
+			if cacheControl != "" && w.Header().Get("Cache-Control") == "" {
+				w.Header().Set("Cache-Control", cacheControl)
+			}
+		}}
+		defer hw.finish()
+		next.ServeHTTP(hw, r)
+	})
+}
+
+// Geração das injeções: muda quando arquivos injetados ou .env mudam, invalidando
+// o ETag das páginas HTML mesmo sem alteração no arquivo em disco
+var injectionGeneration int64
+
+func bumpInjectionGeneration() {
+	atomic.AddInt64(&injectionGeneration, 1)
+}
+
+type etagEntry struct {
+	modTime time.Time
+	size    int64
+	etag    string
+	metaCSP bool // Página HTML com CSP em <meta>: com nonces, o corpo muda a cada resposta
+}
+
+var (
+	etagCache   = make(map[string]etagEntry)
+	etagCacheMu sync.Mutex
+)
+
+// fileETag calcula (e guarda por caminho+mtime+tamanho) o ETag forte do conteúdo de um arquivo
+func fileETag(file string, info os.FileInfo) (string, error) {
+	entry, err := fileEntry(file, info)
+	return entry.etag, err
+}
+
+// fileEntry retorna a entrada do cache de ETags de um arquivo, calculando-a se o arquivo mudou.
+// Para páginas HTML, registra também se o início do arquivo tem uma CSP em <meta>.
+func fileEntry(file string, info os.FileInfo) (etagEntry, error) {
+	etagCacheMu.Lock()
+	entry, ok := etagCache[file]
+	etagCacheMu.Unlock()
+	if ok && entry.modTime.Equal(info.ModTime()) && entry.size == info.Size() {
+		return entry, nil
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return etagEntry{}, err
+	}
+	defer f.Close()
+	hash := sha256.New()
+	var dst io.Writer = hash
+	head := &prefixWriter{max: maxBufferedHead}
+	isHTML := strings.HasPrefix(mime.TypeByExtension(filepath.Ext(file)), "text/html")
+	if isHTML {
+		dst = io.MultiWriter(hash, head)
+	}
+	if _, err := io.Copy(dst, f); err != nil {
+		return etagEntry{}, err
+	}
+	entry = etagEntry{
+		modTime: info.ModTime(),
+		size:    info.Size(),
+		etag:    hex.EncodeToString(hash.Sum(nil)[:16]),
+		metaCSP: isHTML && len(metaCSP(head.buf)) > 0,
+	}
+
+	etagCacheMu.Lock()
+	etagCache[file] = entry
+	etagCacheMu.Unlock()
+	return entry, nil
+}
+
+// prefixWriter guarda só os primeiros 'max' bytes escritos
+type prefixWriter struct {
+	buf []byte
+	max int
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	if room := w.max - len(w.buf); room > 0 {
+		if room > len(p) {
+			room = len(p)
+		}
+		w.buf = append(w.buf, p[:room]...)
+	}
+	return len(p), nil
+}
+
+// htmlETag é o ETag de uma página HTML, que recebe as injeções: fraco, já que o corpo enviado
+// não é o arquivo, e com o início do processo e a geração das injeções, que mudam o corpo
+// (o cliente de live reload tem token e versão por processo)
+func htmlETag(etag string) string {
+	return fmt.Sprintf(`W/"%s-%s-g%d"`, etag, strconv.FormatInt(serverStartTime.UnixNano(), 36), atomic.LoadInt64(&injectionGeneration))
+}
+
+// etagMiddleware define ETags fortes para os arquivos estáticos no modo preview.
+// A verificação de If-None-Match/If-Match e o 304 ficam com http.ServeContent.
+// Com nonces de CSP, as páginas HTML com CSP em <meta> ficam sem ETag: cada resposta tem um
+// nonce novo, e um 304 manteria no navegador um corpo com o nonce de outra resposta. Páginas
+// que recebem a CSP por regra de cabeçalho perdem os validadores em htmlInjector.begin.
+func etagMiddleware(serveDir string, cspNonces bool, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if caching.current() != cacheModePreview || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		file := filepath.Join(serveDir, filepath.FromSlash(path.Clean("/"+r.URL.Path)))
+		info, err := os.Stat(file)
+		if err == nil && info.IsDir() {
+			file = filepath.Join(file, "index.html")
+			info, err = os.Stat(file)
+		}
+		if err == nil && !info.IsDir() {
+			if strings.HasPrefix(mime.TypeByExtension(filepath.Ext(file)), "text/html") {
+				if entry, err := fileEntry(file, info); err == nil {
+					if cspNonces && entry.metaCSP {
+						// Pelo mesmo motivo, sem 304 via Last-Modified
+						r.Header.Del("If-Modified-Since")
+					} else {
+						w.Header().Set("ETag", htmlETag(entry.etag))
+					}
+				}
+			} else if etag, err := fileETag(file, info); err == nil {
+				w.Header().Set("ETag", `"`+etag+`"`)
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleCacheAPI consulta (GET) ou altera (POST {"mode": "preview"}) o modo de cache
+func handleCacheAPI(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var req struct {
+			Mode string `json:"mode"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || !validCacheMode(req.Mode) {
+			http.Error(w, fmt.Sprintf("Requisição inválida: 'mode' deve ser %s ou %s", cacheModeDev, cacheModePreview), http.StatusBadRequest)
+			return
+		}
+		caching.mode.Store(req.Mode)
+		log.Printf("Modo de cache alterado para %s via API", req.Mode)
+	default:
+		http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"mode": caching.current(), "rules": caching.rules})
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	_ "embed"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// defaultReservedPrefix é o prefixo padrão das rotas internas do brhttp
+const defaultReservedPrefix = "/__brhttp"
+
+//go:embed client.js
+var liveReloadClientJS []byte
+
+// liveReloadClientVersion identifica a versão do cliente para invalidar caches
+var liveReloadClientVersion = func() string {
+	sum := sha1.Sum(liveReloadClientJS)
+	return hex.EncodeToString(sum[:])[:10]
+}()
+
+// normalizeReservedPrefix garante um prefixo com '/' inicial e sem '/' final
+func normalizeReservedPrefix(prefix string) string {
+	prefix = strings.TrimRight(strings.TrimSpace(prefix), "/")
+	if prefix == "" {
+		return defaultReservedPrefix
+	}
+	if !strings.HasPrefix(prefix, "/") {
+		prefix = "/" + prefix
+	}
+	return prefix
+}
+
+// publicBaseURL é a public_base_url normalizada, configurada em main. Vazia, as rotas internas
+// são acessadas direto no brhttp; com um proxy reverso que monta o brhttp em um caminho
+// (ex: "/app" ou "https://dev.example.com/app"), as URLs injetadas nas páginas apontam para lá.
+var publicBaseURL string
+
+// normalizePublicBaseURL valida public_base_url e remove a '/' final
+func normalizePublicBaseURL(raw string) (string, error) {
+	base := strings.TrimRight(strings.TrimSpace(raw), "/")
+	if base == "" {
+		return "", nil
+	}
+	u, err := url.Parse(base)
+	if err != nil {
+		return "", fmt.Errorf("public_base_url inválida: %v", err)
+	}
+	if u.IsAbs() {
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return "", fmt.Errorf("public_base_url %q deve usar http ou https", raw)
+		}
+	} else if !strings.HasPrefix(base, "/") || strings.HasPrefix(base, "//") {
+		return "", fmt.Errorf("public_base_url %q deve ser um caminho absoluto (/app) ou uma URL", raw)
+	}
+	if u.RawQuery != "" || u.Fragment != "" {
+		return "", fmt.Errorf("public_base_url %q não deve ter query nem fragmento", raw)
+	}
+	return base, nil
+}
+
+// publicURL retorna a URL de uma rota interna como vista pelo navegador (ex: prefixo + "/client.js")
+func publicURL(urlPath string) string {
+	return publicBaseURL + urlPath
+}
+
+// isReservedPath verifica se o caminho pertence às rotas internas do brhttp
+func isReservedPath(prefix, urlPath string) bool {
+	return urlPath == prefix || strings.HasPrefix(urlPath, prefix+"/")
+}
+
+// nonceAttr retorna o atributo nonce para tags injetadas em páginas com CSP
+func nonceAttr(nonce string) string {
+	if nonce == "" {
+		return ""
+	}
+	return fmt.Sprintf(` nonce="%s"`, nonce)
+}
+
+// liveReloadScriptTag retorna a tag que carrega o cliente de live reload
+func liveReloadScriptTag(prefix, nonce string) string {
+	src := fmt.Sprintf("%s/client.js?v=%s", publicURL(prefix), liveReloadClientVersion)
+	if token := liveReloadAuth.scriptToken(); token != "" {
+		src += "&token=" + token
+	}
+	return fmt.Sprintf(`<script src="%s"%s></script>`, src, nonceAttr(nonce))
+}
+
+// reservedRoutesHandler serve as rotas internas do brhttp sob o prefixo reservado
+func reservedRoutesHandler(prefix string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(prefix+"/client.js", serveLiveReloadClient)
+	// Injeções JS/CSS como arquivos externos (csp_mode "external")
+	mux.HandleFunc(prefix+"/injections/", serveInjection)
+	mux.HandleFunc(prefix+"/env.js", serveEnvJS)
+	mux.HandleFunc(prefix+"/env.json", serveEnvJSON)
+	mux.HandleFunc(prefix+"/ws", liveReloadAuthMiddleware(handleConnections))
+	mux.HandleFunc(prefix+"/events", liveReloadAuthMiddleware(handleEventStream))
+	mux.HandleFunc(prefix+"/poll", liveReloadAuthMiddleware(handlePoll))
+	mux.HandleFunc(prefix+"/send", liveReloadAuthMiddleware(handleClientSend))
+	return mux
+}
+
+// serveLiveReloadClient serve o script do cliente de live reload
+func serveLiveReloadClient(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/javascript; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("ETag", `"`+liveReloadClientVersion+`"`)
+	http.ServeContent(w, r, "client.js", time.Time{}, bytes.NewReader(liveReloadClientJS))
+}
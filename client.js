@@ -0,0 +1,941 @@
+// Cliente de live reload do brhttp. Servido em <prefixo>/client.js e injetado nas páginas HTML.
+(function () {
+    'use strict';
+
+    if (window.__brhttpClient) {
+        return;
+    }
+    window.__brhttpClient = true;
+
+    var script = document.currentScript;
+    var scriptURL = new URL(script && script.src ? script.src : '/__brhttp/client.js', location.href);
+    var basePath = scriptURL.pathname.replace(/\/client\.js$/, '');
+    var wsURL = (scriptURL.protocol === 'https:' ? 'wss:' : 'ws:') + '//' + scriptURL.host + basePath + '/ws';
+    var serverURL = scriptURL.origin + basePath;
+    // Token da sessão, embutido pelo servidor quando live_reload_auth.require_token está ativo
+    var token = scriptURL.searchParams.get('token');
+
+    function withToken(url) {
+        return token ? url + (url.indexOf('?') === -1 ? '?' : '&') + 'token=' + encodeURIComponent(token) : url;
+    }
+
+    var minDelay = 500;
+    var maxDelay = 10000;
+    var delay = minDelay;
+    var wasConnected = false;
+    var connection = null;
+
+    // Transportes em ordem de preferência; o que funcionou fica salvo para as próximas recargas
+    var transports = ['websocket', 'sse', 'polling'];
+    var transportKey = 'brhttp-transport';
+    var transportIndex = Math.max(0, transports.indexOf(sessionStorage.getItem(transportKey)));
+    var transportFailures = 0;
+
+    function resolveURL(url, base) {
+        try {
+            return new URL(url, base || location.href);
+        } catch (e) {
+            return null;
+        }
+    }
+
+    function cacheBust(url, base) {
+        var u = resolveURL(url, base);
+        u.searchParams.set('v', Date.now());
+        return u.pathname + u.search + u.hash;
+    }
+
+    function matchesPath(url, path, base) {
+        if (!url) {
+            return false;
+        }
+        var u = resolveURL(url, base);
+        return !!u && u.origin === location.origin && u.pathname === path;
+    }
+
+    // Substitui as referências url(...) ao caminho alterado; retorna null se não houver nenhuma
+    function bustCSSURLs(text, path, base) {
+        var changed = false;
+        var result = text.replace(/url\(\s*(['"]?)([^'")]+)\1\s*\)/g, function (match, quote, url) {
+            if (!matchesPath(url, path, base)) {
+                return match;
+            }
+            changed = true;
+            return 'url("' + cacheBust(url, base) + '")';
+        });
+        return changed ? result : null;
+    }
+
+    function bustSrcset(value, path) {
+        var changed = false;
+        var result = value.split(',').map(function (candidate) {
+            var parts = candidate.trim().split(/\s+/);
+            if (matchesPath(parts[0], path)) {
+                parts[0] = cacheBust(parts[0]);
+                changed = true;
+            }
+            return parts.join(' ');
+        }).join(', ');
+        return changed ? result : null;
+    }
+
+    function ruleBase(rule) {
+        return (rule.parentStyleSheet && rule.parentStyleSheet.href) || location.href;
+    }
+
+    // Percorre todas as regras do CSSOM, incluindo folhas importadas via @import e blocos @media
+    function eachRule(container, callback) {
+        var rules;
+        try {
+            rules = container.cssRules;
+        } catch (e) {
+            return; // Folha de outra origem
+        }
+        if (!rules) {
+            return;
+        }
+        for (var i = 0; i < rules.length; i++) {
+            callback(rules[i], container, i);
+            if (rules[i].styleSheet) {
+                eachRule(rules[i].styleSheet, callback);
+            } else if (rules[i].cssRules) {
+                eachRule(rules[i], callback);
+            }
+        }
+    }
+
+    function eachDocumentRule(callback) {
+        for (var i = 0; i < document.styleSheets.length; i++) {
+            eachRule(document.styleSheets[i], callback);
+        }
+    }
+
+    var hotFonts = {};
+
+    var handlers = {
+        'reload': function () {
+            location.reload();
+        },
+        'css-update': function (message) {
+            var found = false;
+            var links = document.querySelectorAll('link[rel="stylesheet"]');
+            for (var i = 0; i < links.length; i++) {
+                if (matchesPath(links[i].getAttribute('href'), message.path)) {
+                    links[i].href = cacheBust(links[i].getAttribute('href'));
+                    found = true;
+                }
+            }
+
+            // Folhas carregadas via @import: substitui a regra por uma com cache-busting
+            var imports = [];
+            eachDocumentRule(function (rule, parent, index) {
+                if (rule.type === CSSRule.IMPORT_RULE && matchesPath(rule.href, message.path, ruleBase(rule))) {
+                    imports.push({ rule: rule, parent: parent, index: index });
+                }
+            });
+            imports.forEach(function (item) {
+                var media = item.rule.media && item.rule.media.mediaText;
+                var text = '@import url("' + cacheBust(item.rule.href, ruleBase(item.rule)) + '")' + (media ? ' ' + media : '') + ';';
+                try {
+                    item.parent.deleteRule(item.index);
+                    item.parent.insertRule(text, item.index);
+                    found = true;
+                } catch (e) {}
+            });
+
+            // <style> inline que importa a folha alterada
+            if (!found) {
+                var styles = document.querySelectorAll('style');
+                for (var j = 0; j < styles.length; j++) {
+                    var text = bustCSSURLs(styles[j].textContent, message.path);
+                    if (text) {
+                        styles[j].textContent = text;
+                        found = true;
+                    }
+                }
+            }
+
+            if (!found) {
+                location.reload();
+            }
+        },
+        'image-update': function (message) {
+            var path = message.path;
+            var found = false;
+
+            var attributes = [['img', 'src'], ['img', 'srcset'], ['source', 'src'], ['source', 'srcset'],
+                ['video', 'poster'], ['input', 'src'], ['link[rel~="icon"]', 'href'], ['image', 'href'], ['use', 'href']];
+            attributes.forEach(function (pair) {
+                var elements = document.querySelectorAll(pair[0] + '[' + pair[1] + ']');
+                for (var i = 0; i < elements.length; i++) {
+                    var value = elements[i].getAttribute(pair[1]);
+                    var updated = pair[1] === 'srcset' ? bustSrcset(value, path) : (matchesPath(value, path) ? cacheBust(value) : null);
+                    if (updated) {
+                        elements[i].setAttribute(pair[1], updated);
+                        found = true;
+                    }
+                }
+            });
+
+            // SVG com xlink:href
+            var svgImages = document.querySelectorAll('image, use');
+            for (var i = 0; i < svgImages.length; i++) {
+                var xlink = svgImages[i].getAttributeNS('http://www.w3.org/1999/xlink', 'href');
+                if (matchesPath(xlink, path)) {
+                    svgImages[i].setAttributeNS('http://www.w3.org/1999/xlink', 'href', cacheBust(xlink));
+                    found = true;
+                }
+            }
+
+            // Atributos style inline com url(...)
+            var inline = document.querySelectorAll('[style*="url("]');
+            for (var j = 0; j < inline.length; j++) {
+                var style = bustCSSURLs(inline[j].getAttribute('style'), path);
+                if (style) {
+                    inline[j].setAttribute('style', style);
+                    found = true;
+                }
+            }
+
+            // Regras CSS (background-image, mask, list-style-image, etc.)
+            eachDocumentRule(function (rule) {
+                if (!rule.style || rule.type === CSSRule.FONT_FACE_RULE) {
+                    return;
+                }
+                for (var k = 0; k < rule.style.length; k++) {
+                    var property = rule.style[k];
+                    var value = rule.style.getPropertyValue(property);
+                    var updated = value.indexOf('url(') !== -1 && bustCSSURLs(value, path, ruleBase(rule));
+                    if (updated) {
+                        rule.style.setProperty(property, updated, rule.style.getPropertyPriority(property));
+                        found = true;
+                    }
+                }
+            });
+
+            if (!found) {
+                location.reload();
+            }
+        },
+        'font-update': function (message) {
+            if (!document.fonts || !window.FontFace) {
+                location.reload();
+                return;
+            }
+            var faces = [];
+            eachDocumentRule(function (rule) {
+                if (rule.type === CSSRule.FONT_FACE_RULE) {
+                    var src = rule.style.getPropertyValue('src');
+                    var updated = src && bustCSSURLs(src, message.path, ruleBase(rule));
+                    if (updated) {
+                        faces.push({ rule: rule, src: updated });
+                    }
+                }
+            });
+            if (!faces.length) {
+                location.reload();
+                return;
+            }
+
+            var previous = hotFonts[message.path] || [];
+            var added = hotFonts[message.path] = [];
+            faces.forEach(function (item) {
+                var style = item.rule.style;
+                try {
+                    style.setProperty('src', item.src);
+                } catch (e) {}
+                var descriptors = {};
+                [['font-style', 'style'], ['font-weight', 'weight'], ['font-stretch', 'stretch'],
+                    ['unicode-range', 'unicodeRange'], ['font-display', 'display']].forEach(function (pair) {
+                    var value = style.getPropertyValue(pair[0]);
+                    if (value) {
+                        descriptors[pair[1]] = value;
+                    }
+                });
+                var family = style.getPropertyValue('font-family').trim().replace(/^['"]|['"]$/g, '');
+                var face = new FontFace(family, item.src, descriptors);
+                face.load().then(function (loaded) {
+                    document.fonts.add(loaded);
+                    added.push(loaded);
+                }).catch(function () {});
+            });
+            previous.forEach(function (face) {
+                document.fonts.delete(face);
+            });
+        },
+        'js-update': function (message) {
+            var scripts = document.querySelectorAll('script[src]');
+            for (var i = 0; i < scripts.length; i++) {
+                var old = scripts[i];
+                if (matchesPath(old.getAttribute('src'), message.path)) {
+                    var newScript = document.createElement('script');
+                    newScript.src = cacheBust(old.getAttribute('src'));
+                    newScript.async = true;
+                    old.parentNode.replaceChild(newScript, old);
+                    return;
+                }
+            }
+            location.reload();
+        }
+    };
+
+    // Runtime de HMR para módulos ES: os módulos reescritos pelo servidor recebem import.meta.hot
+    var hotContexts = {};
+    var hotData = {};
+
+    function moduleKey(url) {
+        var u = resolveURL(url);
+        return u ? u.pathname : url;
+    }
+
+    function HotContext(key) {
+        this.key = key;
+        this.data = hotData[key] || (hotData[key] = {});
+        this.selfCallbacks = [];
+        this.depCallbacks = [];
+        this.disposeCallbacks = [];
+        this.declined = false;
+    }
+
+    HotContext.prototype.accept = function (deps, callback) {
+        if (deps === undefined || typeof deps === 'function') {
+            this.selfCallbacks.push(deps || function () {});
+            return;
+        }
+        var base = location.origin + this.key;
+        var list = Array.isArray(deps) ? deps : [deps];
+        this.depCallbacks.push({
+            deps: list.map(function (dep) {
+                return moduleKey(resolveURL(dep, base).href);
+            }),
+            single: !Array.isArray(deps),
+            callback: callback || function () {}
+        });
+    };
+
+    HotContext.prototype.dispose = function (callback) {
+        this.disposeCallbacks.push(callback);
+    };
+
+    HotContext.prototype.decline = function () {
+        this.declined = true;
+    };
+
+    HotContext.prototype.invalidate = function () {
+        location.reload();
+    };
+
+    window.__brhttpHMR = {
+        createHotContext: function (url) {
+            var key = moduleKey(url);
+            var context = new HotContext(key);
+            hotContexts[key] = context;
+            return context;
+        }
+    };
+
+    function applyHotUpdate(update) {
+        var boundary = hotContexts[update.boundary];
+        if (!boundary || boundary.declined) {
+            return Promise.reject(new Error('nenhum módulo aceita ' + update.path));
+        }
+        var old = hotContexts[update.path];
+        if (old) {
+            old.disposeCallbacks.forEach(function (callback) {
+                callback(old.data);
+            });
+        }
+        var selfCallbacks = boundary.selfCallbacks.slice();
+        var depCallbacks = boundary.depCallbacks.slice();
+        return import(update.path + '?v=' + update.version).then(function (mod) {
+            if (update.path === update.boundary) {
+                selfCallbacks.forEach(function (callback) {
+                    callback(mod);
+                });
+                return;
+            }
+            depCallbacks.forEach(function (entry) {
+                var index = entry.deps.indexOf(update.path);
+                if (index === -1) {
+                    return;
+                }
+                entry.callback(entry.single ? mod : entry.deps.map(function (dep, i) {
+                    return i === index ? mod : undefined;
+                }));
+            });
+        });
+    }
+
+    function send(message) {
+        if (connection && connection.open) {
+            connection.send(JSON.stringify(message));
+        }
+    }
+
+    // Recursos da mesma origem carregados pela página, para recarga direcionada
+    function loadedResources(entries) {
+        var resources = [];
+        for (var i = 0; i < entries.length; i++) {
+            try {
+                var u = new URL(entries[i].name, location.href);
+                if (u.origin === location.origin && u.pathname.indexOf(basePath + '/') !== 0) {
+                    resources.push(u.pathname);
+                }
+            } catch (e) {}
+        }
+        return resources;
+    }
+
+    function currentPage() {
+        return location.pathname + location.search;
+    }
+
+    function currentViewport() {
+        return { width: window.innerWidth, height: window.innerHeight };
+    }
+
+    var resizeTimer = null;
+    window.addEventListener('resize', function () {
+        clearTimeout(resizeTimer);
+        resizeTimer = setTimeout(function () {
+            send({ type: 'viewport', viewport: currentViewport() });
+        }, 250);
+    });
+
+    var pendingResources = [];
+    var pendingTimer = null;
+
+    if (window.PerformanceObserver) {
+        try {
+            new PerformanceObserver(function (list) {
+                pendingResources = pendingResources.concat(loadedResources(list.getEntries()));
+                if (!pendingTimer) {
+                    pendingTimer = setTimeout(function () {
+                        pendingTimer = null;
+                        if (pendingResources.length) {
+                            send({ type: 'deps', resources: pendingResources });
+                            pendingResources = [];
+                        }
+                    }, 200);
+                }
+            }).observe({ type: 'resource', buffered: false });
+        } catch (e) {}
+    }
+
+    // Sincronização de interações entre dispositivos (habilitada pelo servidor via sync-config)
+    var syncConfig = { enabled: false, exclude: [], events: [] };
+    var applyingSync = false;
+    var remoteScrollUntil = 0;
+    var syncNavigationKey = 'brhttp-sync-navigation';
+
+    function syncAllows(event) {
+        return syncConfig.enabled && !applyingSync && syncConfig.events.indexOf(event) !== -1;
+    }
+
+    function isSyncExcluded(element) {
+        if (!element || !element.closest) {
+            return true;
+        }
+        if (element.type === 'password' || element.closest('[data-brhttp-nosync]')) {
+            return true;
+        }
+        for (var i = 0; i < syncConfig.exclude.length; i++) {
+            try {
+                if (element.closest(syncConfig.exclude[i])) {
+                    return true;
+                }
+            } catch (e) {}
+        }
+        return false;
+    }
+
+    // Seletor CSS único o suficiente para localizar o mesmo elemento em outro dispositivo
+    function selectorFor(element) {
+        var parts = [];
+        while (element && element.nodeType === 1 && element !== document.documentElement) {
+            if (element.id) {
+                parts.unshift('#' + CSS.escape(element.id));
+                break;
+            }
+            var index = 1;
+            for (var sibling = element.previousElementSibling; sibling; sibling = sibling.previousElementSibling) {
+                if (sibling.tagName === element.tagName) {
+                    index++;
+                }
+            }
+            parts.unshift(element.tagName.toLowerCase() + ':nth-of-type(' + index + ')');
+            element = element.parentElement;
+        }
+        return parts.join(' > ');
+    }
+
+    function reportSync(event, data) {
+        if (!syncAllows(event)) {
+            return;
+        }
+        data.type = 'sync';
+        data.event = event;
+        send(data);
+    }
+
+    function scrollRatio(position, total, viewport) {
+        var max = total - viewport;
+        return max > 0 ? position / max : 0;
+    }
+
+    var scrollTimer = null;
+    window.addEventListener('scroll', function () {
+        if (scrollTimer || Date.now() < remoteScrollUntil) {
+            return;
+        }
+        scrollTimer = setTimeout(function () {
+            scrollTimer = null;
+            var root = document.documentElement;
+            reportSync('scroll', {
+                x: scrollRatio(window.scrollX, root.scrollWidth, window.innerWidth),
+                y: scrollRatio(window.scrollY, root.scrollHeight, window.innerHeight)
+            });
+        }, 100);
+    }, { passive: true });
+
+    document.addEventListener('click', function (event) {
+        if (event.isTrusted && !isSyncExcluded(event.target)) {
+            reportSync('click', { selector: selectorFor(event.target) });
+        }
+    }, true);
+
+    document.addEventListener('input', function (event) {
+        var target = event.target;
+        if (event.isTrusted && !isSyncExcluded(target)) {
+            reportSync('input', { selector: selectorFor(target), value: target.value, checked: target.checked });
+        }
+    }, true);
+
+    // Navegações sincronizadas só podem levar a páginas do próprio site (nunca javascript: etc.)
+    function isSameOrigin(url) {
+        try {
+            return new URL(url, location.href).origin === location.origin;
+        } catch (e) {
+            return false;
+        }
+    }
+
+    function applySync(message) {
+        applyingSync = true;
+        try {
+            var root = document.documentElement;
+            var element = message.selector ? document.querySelector(message.selector) : null;
+            switch (message.event) {
+            case 'scroll':
+                remoteScrollUntil = Date.now() + 300;
+                window.scrollTo(message.x * (root.scrollWidth - window.innerWidth), message.y * (root.scrollHeight - window.innerHeight));
+                break;
+            case 'click':
+                if (element && !isSyncExcluded(element)) {
+                    element.click();
+                }
+                break;
+            case 'input':
+                if (element && !isSyncExcluded(element)) {
+                    if (element.type === 'checkbox' || element.type === 'radio') {
+                        element.checked = message.checked;
+                    } else {
+                        element.value = message.value;
+                    }
+                    element.dispatchEvent(new Event('input', { bubbles: true }));
+                    element.dispatchEvent(new Event('change', { bubbles: true }));
+                }
+                break;
+            case 'navigate':
+                if (message.url && message.url !== currentPage() && isSameOrigin(message.url)) {
+                    sessionStorage.setItem(syncNavigationKey, message.url);
+                    location.href = message.url;
+                }
+                break;
+            }
+        } finally {
+            applyingSync = false;
+        }
+    }
+
+    handlers['sync-config'] = function (message) {
+        var wasEnabled = syncConfig.enabled;
+        syncConfig = { enabled: message.enabled, exclude: message.exclude || [], events: message.events || [] };
+        if (syncConfig.enabled && !wasEnabled) {
+            // Página carregada por uma navegação remota não é anunciada de volta
+            if (sessionStorage.getItem(syncNavigationKey) === currentPage()) {
+                sessionStorage.removeItem(syncNavigationKey);
+            } else {
+                reportSync('navigate', { url: currentPage() });
+            }
+        }
+    };
+
+    handlers['sync'] = applySync;
+
+    handlers['navigate'] = function (message) {
+        if (message.url && isSameOrigin(message.url)) {
+            location.href = message.url;
+        }
+    };
+
+    // Navegação em SPAs (pushState/replaceState/popstate)
+    function notifyNavigation() {
+        send({ type: 'navigate', url: currentPage() });
+        reportSync('navigate', { url: currentPage() });
+    }
+    ['pushState', 'replaceState'].forEach(function (method) {
+        var original = history[method];
+        history[method] = function () {
+            var result = original.apply(this, arguments);
+            notifyNavigation();
+            return result;
+        };
+    });
+    window.addEventListener('popstate', notifyNavigation);
+
+    // Encaminhamento do console e de erros não tratados (habilitado pelo servidor via console-config).
+    // Mensagens anteriores à configuração ficam em um buffer para não perder erros do carregamento.
+    var consoleConfig = null;
+    var consoleBuffer = [];
+    var maxConsoleBuffer = 100;
+    var maxConsoleMessage = 4000;
+    var forwardingConsole = false;
+
+    function formatConsoleArg(arg) {
+        if (arg instanceof Error) {
+            return arg.stack || String(arg);
+        }
+        if (typeof arg === 'string') {
+            return arg;
+        }
+        if (arg && typeof arg === 'object') {
+            if (arg.nodeType === 1) {
+                return '<' + arg.tagName.toLowerCase() + (arg.id ? '#' + arg.id : '') + '>';
+            }
+            var seen = [];
+            try {
+                return JSON.stringify(arg, function (key, value) {
+                    if (value && typeof value === 'object') {
+                        if (seen.indexOf(value) !== -1) {
+                            return '[circular]';
+                        }
+                        seen.push(value);
+                    }
+                    return value;
+                });
+            } catch (e) {}
+        }
+        return String(arg);
+    }
+
+    function forwardConsole(entry) {
+        if (forwardingConsole) {
+            return;
+        }
+        entry.type = 'console';
+        entry.url = currentPage();
+        if (entry.message.length > maxConsoleMessage) {
+            entry.message = entry.message.slice(0, maxConsoleMessage) + '…';
+        }
+        if (consoleConfig === null) {
+            if (consoleBuffer.length < maxConsoleBuffer) {
+                consoleBuffer.push(entry);
+            }
+            return;
+        }
+        if (!consoleConfig.enabled || consoleConfig.levels.indexOf(entry.level) === -1) {
+            return;
+        }
+        forwardingConsole = true;
+        try {
+            send(entry);
+        } finally {
+            forwardingConsole = false;
+        }
+    }
+
+    ['log', 'info', 'warn', 'error', 'debug'].forEach(function (level) {
+        var original = console[level];
+        if (typeof original !== 'function') {
+            return;
+        }
+        console[level] = function () {
+            var args = Array.prototype.slice.call(arguments);
+            var stack = '';
+            for (var i = 0; i < args.length; i++) {
+                if (args[i] instanceof Error && args[i].stack) {
+                    stack = args[i].stack;
+                    break;
+                }
+            }
+            forwardConsole({ level: level, source: 'console', message: args.map(formatConsoleArg).join(' '), stack: stack });
+            return original.apply(this, arguments);
+        };
+    });
+
+    window.addEventListener('error', function (event) {
+        // Falhas de carregamento de recursos não têm mensagem; apenas erros de script são encaminhados
+        if (!event.message) {
+            return;
+        }
+        var position = event.filename ? ' (' + event.filename + ':' + event.lineno + ':' + event.colno + ')' : '';
+        forwardConsole({
+            level: 'error',
+            source: 'error',
+            message: event.message + position,
+            stack: event.error && event.error.stack ? event.error.stack : ''
+        });
+    });
+
+    window.addEventListener('unhandledrejection', function (event) {
+        var reason = event.reason;
+        forwardConsole({
+            level: 'error',
+            source: 'unhandledrejection',
+            message: 'Promise rejeitada sem tratamento: ' + formatConsoleArg(reason),
+            stack: reason && reason.stack ? reason.stack : ''
+        });
+    });
+
+    handlers['console-config'] = function (message) {
+        consoleConfig = { enabled: !!message.enabled, levels: message.levels || [] };
+        var buffered = consoleBuffer;
+        consoleBuffer = [];
+        buffered.forEach(forwardConsole);
+    };
+
+    // API para a aplicação reagir a eventos enviados via POST /api/broadcast:
+    // window.brhttp.on('flags', function (payload, message) { ... }) retorna uma função para cancelar.
+    // Cada evento com handler vira uma inscrição no tópico custom:<evento> do servidor.
+    var customHandlers = {};
+
+    function customTopics() {
+        return Object.keys(customHandlers).map(function (event) {
+            return 'custom:' + event;
+        });
+    }
+
+    function offCustom(event, handler) {
+        var list = customHandlers[event];
+        if (!list) {
+            return;
+        }
+        var index = list.indexOf(handler);
+        if (index !== -1) {
+            list.splice(index, 1);
+        }
+        if (!list.length) {
+            delete customHandlers[event];
+            send({ type: 'unsubscribe', topics: ['custom:' + event] });
+        }
+    }
+
+    window.brhttp = {
+        on: function (event, handler) {
+            if (!customHandlers[event]) {
+                customHandlers[event] = [];
+                send({ type: 'subscribe', topics: ['custom:' + event] });
+            }
+            customHandlers[event].push(handler);
+            return function () {
+                offCustom(event, handler);
+            };
+        },
+        off: offCustom
+    };
+
+    handlers['custom'] = function (message) {
+        var list = (customHandlers[message.event] || []).concat(customHandlers['*'] || []);
+        list.forEach(function (handler) {
+            try {
+                handler(message.payload, message);
+            } catch (err) {
+                console.error('[brhttp] Erro no handler do evento "' + message.event + '":', err);
+            }
+        });
+    };
+
+    handlers['hmr-update'] = function (message) {
+        Promise.all(message.updates.map(applyHotUpdate)).then(function () {
+            console.log('[brhttp] HMR: ' + message.path + ' atualizado');
+        }).catch(function (err) {
+            console.warn('[brhttp] HMR falhou, recarregando a página:', err);
+            location.reload();
+        });
+    };
+
+    function handleMessage(data) {
+        var message;
+        try {
+            message = JSON.parse(data);
+        } catch (e) {
+            return;
+        }
+        var handler = handlers[message.type];
+        if (handler) {
+            handler(message);
+        }
+    }
+
+    // Envio do navegador para o servidor nos transportes SSE e long polling
+    function postMessage(clientID, data) {
+        fetch(withToken(serverURL + '/send?client=' + encodeURIComponent(clientID)), {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: data,
+            keepalive: true
+        }).catch(function () {});
+    }
+
+    function openWebSocket(events) {
+        var socket = new WebSocket(withToken(wsURL));
+        var conn = {
+            open: false,
+            send: function (data) {
+                socket.send(data);
+            }
+        };
+        socket.onopen = function () {
+            conn.open = true;
+            events.open();
+        };
+        socket.onmessage = function (event) {
+            handleMessage(event.data);
+        };
+        socket.onclose = function () {
+            events.close(conn.open);
+        };
+        return conn;
+    }
+
+    function openEventSource(events) {
+        var source = new EventSource(withToken(serverURL + '/events'));
+        var clientID = null;
+        var conn = {
+            open: false,
+            send: function (data) {
+                postMessage(clientID, data);
+            }
+        };
+        source.onmessage = function (event) {
+            var message;
+            try {
+                message = JSON.parse(event.data);
+            } catch (e) {
+                return;
+            }
+            if (message.type === 'welcome') {
+                clientID = message.client_id;
+                conn.open = true;
+                events.open();
+                return;
+            }
+            handleMessage(event.data);
+        };
+        source.onerror = function () {
+            // A reconexão automática do EventSource é substituída pela nossa (com backoff e fallback)
+            source.close();
+            events.close(conn.open);
+        };
+        return conn;
+    }
+
+    function openPolling(events) {
+        var clientID = null;
+        var conn = {
+            open: false,
+            send: function (data) {
+                postMessage(clientID, data);
+            }
+        };
+        function poll() {
+            var url = serverURL + '/poll' + (clientID ? '?client=' + encodeURIComponent(clientID) : '');
+            fetch(withToken(url), { cache: 'no-store' }).then(function (response) {
+                if (!response.ok) {
+                    throw new Error('HTTP ' + response.status);
+                }
+                return response.json();
+            }).then(function (messages) {
+                messages.forEach(function (message) {
+                    if (message.type === 'welcome') {
+                        clientID = message.client_id;
+                        conn.open = true;
+                        events.open();
+                    } else {
+                        handleMessage(JSON.stringify(message));
+                    }
+                });
+                poll();
+            }).catch(function () {
+                events.close(conn.open);
+            });
+        }
+        poll();
+        return conn;
+    }
+
+    var openers = { websocket: openWebSocket, sse: openEventSource, polling: openPolling };
+
+    function scheduleReconnect() {
+        setTimeout(connect, delay);
+        delay = Math.min(delay * 2, maxDelay);
+    }
+
+    function onOpen() {
+        delay = minDelay;
+        transportFailures = 0;
+        sessionStorage.setItem(transportKey, transports[transportIndex]);
+        if (wasConnected) {
+            // O servidor voltou após uma queda: recarrega para pegar o estado atual
+            location.reload();
+            return;
+        }
+        wasConnected = true;
+        send({
+            type: 'hello',
+            url: currentPage(),
+            viewport: currentViewport(),
+            topics: customTopics(),
+            resources: loadedResources(performance.getEntriesByType ? performance.getEntriesByType('resource') : [])
+        });
+    }
+
+    function onClose(opened) {
+        connection = null;
+        // Um transporte que nunca abriu (e nunca conectamos) provavelmente está bloqueado: tenta o próximo
+        if (!opened && !wasConnected && ++transportFailures >= 2 && transportIndex < transports.length - 1) {
+            transportIndex++;
+            transportFailures = 0;
+            console.info('[brhttp] Live reload usando ' + transports[transportIndex]);
+            connect();
+            return;
+        }
+        scheduleReconnect();
+    }
+
+    function connect() {
+        var name = transports[transportIndex];
+        if ((name === 'websocket' && !window.WebSocket) || (name === 'sse' && !window.EventSource)) {
+            transportIndex++;
+            connect();
+            return;
+        }
+        var closed = false;
+        try {
+            connection = openers[name]({
+                open: onOpen,
+                close: function (opened) {
+                    if (!closed) {
+                        closed = true;
+                        onClose(opened);
+                    }
+                }
+            });
+        } catch (e) {
+            onClose(false);
+        }
+    }
+
+    connect();
+})();
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+var mobileUserAgentPattern = regexp.MustCompile(`(?i)mobi|android|iphone|ipad|ipod`)
+
+// newClientID gera um identificador curto e aleatório para um cliente conectado
+func newClientID() string {
+	b := make([]byte, 6)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// clientViewport é o tamanho da janela relatado pelo navegador
+type clientViewport struct {
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// clientInfo é a representação JSON de um cliente conectado
+type clientInfo struct {
+	ID          string         `json:"id"`
+	Transport   string         `json:"transport"`
+	UserAgent   string         `json:"user_agent"`
+	Mobile      bool           `json:"mobile"`
+	URL         string         `json:"url"`
+	Page        string         `json:"page"`
+	Viewport    clientViewport `json:"viewport"`
+	ConnectedAt time.Time      `json:"connected_at"`
+	RemoteAddr  string         `json:"remote_addr"`
+	Host        string         `json:"host"`
+	Topics      []string       `json:"topics"`
+	Dropped     int64          `json:"dropped_messages"`
+}
+
+func (c *Client) info() clientInfo {
+	topics := c.subscriptions()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return clientInfo{
+		ID:          c.id,
+		Transport:   c.transport,
+		UserAgent:   c.userAgent,
+		Mobile:      mobileUserAgentPattern.MatchString(c.userAgent),
+		URL:         c.url,
+		Page:        c.page,
+		Viewport:    c.viewport,
+		ConnectedAt: c.connectedAt,
+		RemoteAddr:  c.remoteAddr,
+		Host:        c.host,
+		Topics:      topics,
+		Dropped:     atomic.LoadInt64(&c.dropped),
+	}
+}
+
+// clientFilter seleciona um grupo de clientes; campos vazios não filtram
+type clientFilter struct {
+	ID        string `json:"id"`
+	URLPrefix string `json:"url_prefix"`
+	UserAgent string `json:"user_agent"` // Substring (sem diferenciar maiúsculas)
+	Mobile    *bool  `json:"mobile"`
+	Host      string `json:"host"`
+}
+
+// clientFilterFromQuery lê o filtro dos parâmetros da URL
+func clientFilterFromQuery(r *http.Request) clientFilter {
+	q := r.URL.Query()
+	filter := clientFilter{
+		ID:        q.Get("id"),
+		URLPrefix: q.Get("url_prefix"),
+		UserAgent: q.Get("user_agent"),
+		Host:      q.Get("host"),
+	}
+	if mobile, err := strconv.ParseBool(q.Get("mobile")); err == nil {
+		filter.Mobile = &mobile
+	}
+	return filter
+}
+
+func (f clientFilter) matches(c *Client) bool {
+	info := c.info()
+	if f.ID != "" && info.ID != f.ID {
+		return false
+	}
+	if f.URLPrefix != "" && !strings.HasPrefix(info.Page, f.URLPrefix) && !strings.HasPrefix(info.URL, f.URLPrefix) {
+		return false
+	}
+	if f.UserAgent != "" && !strings.Contains(strings.ToLower(info.UserAgent), strings.ToLower(f.UserAgent)) {
+		return false
+	}
+	if f.Mobile != nil && info.Mobile != *f.Mobile {
+		return false
+	}
+	if f.Host != "" && info.Host != f.Host {
+		return false
+	}
+	return true
+}
+
+// matchingClients retorna os clientes conectados que satisfazem o filtro
+func matchingClients(filter clientFilter) []*Client {
+	var matched []*Client
+	for _, client := range hub.snapshot() {
+		if filter.matches(client) {
+			matched = append(matched, client)
+		}
+	}
+	return matched
+}
+
+// sendToClients envia uma mensagem aos clientes que satisfazem o filtro e retorna quantos eram
+func sendToClients(filter clientFilter, message []byte) int {
+	matched := matchingClients(filter)
+	if len(matched) == 0 {
+		return 0
+	}
+	set := make(map[*Client]bool, len(matched))
+	for _, client := range matched {
+		set[client] = true
+	}
+	hub.publish(outboundMessage{data: message, target: func(c *Client) bool { return set[c] }})
+	return len(matched)
+}
+
+// Tipos de mensagem do protocolo interno, que a ação "message" não pode enviar: navegação,
+// sincronização e atualizações passam pelas ações e rotas próprias, que validam os campos
+var reservedMessageTypes = map[string]bool{
+	"welcome": true, "reload": true, "navigate": true,
+	"css-update": true, "js-update": true, "image-update": true, "font-update": true, "hmr-update": true,
+	"sync": true, "sync-config": true, "console-config": true, "custom": true,
+}
+
+// handleClientsAPI lista os clientes (GET /api/clients) e executa ações em um cliente
+// (/api/clients/{id}/{ação}) ou em um grupo filtrado (/api/clients/{ação}?mobile=true&url_prefix=/checkout).
+// Ações: reload, navigate ({"url": "/caminho"}, apenas da mesma origem) e message (objeto JSON
+// enviado como está, com um 'type' que não seja do protocolo interno).
+func handleClientsAPI(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/clients"), "/"), "/")
+	filter := clientFilterFromQuery(r)
+
+	if parts[0] == "" {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+			return
+		}
+		infos := []clientInfo{}
+		for _, client := range matchingClients(filter) {
+			infos = append(infos, client.info())
+		}
+		sort.Slice(infos, func(a, b int) bool { return infos[a].ConnectedAt.Before(infos[b].ConnectedAt) })
+		writeJSON(w, http.StatusOK, infos)
+		return
+	}
+
+	action := parts[0]
+	if len(parts) == 2 {
+		filter = clientFilter{ID: parts[0]}
+		action = parts[1]
+	} else if len(parts) > 2 {
+		http.NotFound(w, r)
+		return
+	}
+
+	if len(parts) == 1 && r.Method == http.MethodGet {
+		// GET /api/clients/{id}
+		matched := matchingClients(clientFilter{ID: parts[0]})
+		if len(matched) == 0 {
+			http.Error(w, "Cliente não encontrado", http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, matched[0].info())
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var message []byte
+	switch action {
+	case "reload":
+		message, _ = json.Marshal(map[string]string{"type": "reload"})
+	case "navigate":
+		var req struct {
+			URL string `json:"url"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.URL == "" {
+			http.Error(w, "Requisição inválida: campo 'url' obrigatório", http.StatusBadRequest)
+			return
+		}
+		if !sameOriginPath(req.URL) {
+			http.Error(w, "Requisição inválida: 'url' deve ser um caminho do próprio servidor (ex: /pagina)", http.StatusBadRequest)
+			return
+		}
+		message, _ = json.Marshal(map[string]string{"type": "navigate", "url": req.URL})
+	case "message":
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+		var payload map[string]interface{}
+		if err != nil || json.Unmarshal(body, &payload) != nil {
+			http.Error(w, "Requisição inválida: esperado um objeto JSON", http.StatusBadRequest)
+			return
+		}
+		messageType, ok := payload["type"].(string)
+		if !ok {
+			http.Error(w, "Requisição inválida: campo 'type' obrigatório", http.StatusBadRequest)
+			return
+		}
+		if reservedMessageTypes[messageType] {
+			http.Error(w, "Requisição inválida: o tipo '"+messageType+"' é reservado ao protocolo interno", http.StatusBadRequest)
+			return
+		}
+		message = body
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	sent := sendToClients(filter, message)
+	if sent == 0 && filter.ID != "" {
+		http.Error(w, "Cliente não encontrado", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]int{"sent_to": sent})
+}
+
+// customTopic é o tópico em que um cliente se inscreve ao registrar window.brhttp.on(evento)
+func customTopic(event string) string {
+	return "custom:" + event
+}
+
+// handleBroadcastAPI envia um evento da aplicação (POST /api/broadcast) às páginas abertas:
+// {"event": "flags", "payload": {...}, "target": {"url_prefix": "/admin"}}.
+// Apenas clientes com um handler para o evento (ou para "*") o recebem.
+func handleBroadcastAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		Event   string          `json:"event"`
+		Payload json.RawMessage `json:"payload"`
+		Target  clientFilter    `json:"target"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil || req.Event == "" {
+		http.Error(w, "Requisição inválida: campo 'event' obrigatório", http.StatusBadRequest)
+		return
+	}
+	if len(req.Payload) == 0 {
+		req.Payload = json.RawMessage("null")
+	}
+	message, _ := json.Marshal(map[string]interface{}{"type": "custom", "event": req.Event, "payload": req.Payload})
+
+	set := make(map[*Client]bool)
+	for _, client := range matchingClients(req.Target) {
+		if client.subscribed(customTopic(req.Event)) || client.subscribed(customTopic("*")) {
+			set[client] = true
+		}
+	}
+	if len(set) > 0 {
+		hub.publish(outboundMessage{data: message, target: func(c *Client) bool { return set[c] }})
+	}
+	writeJSON(w, http.StatusOK, map[string]int{"sent_to": len(set)})
+}
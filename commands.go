@@ -0,0 +1,216 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// defaultParamPattern é usado para parâmetros sem 'pattern' nem 'enum'
+var defaultParamPattern = regexp.MustCompile(`^[A-Za-z0-9_.,:@=/+-]*$`)
+
+var commandTemplateVar = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_]+)\s*\}\}`)
+
+// CommandParam define um parâmetro aceito por um comando nomeado
+type CommandParam struct {
+	Pattern   string   `json:"pattern"` // Regex que o valor inteiro deve satisfazer
+	Enum      []string `json:"enum"`    // Lista de valores permitidos
+	Required  bool     `json:"required"`
+	Default   string   `json:"default"`
+	MaxLength int      `json:"max_length"`
+
+	pattern *regexp.Regexp
+}
+
+// CommandDefinition define um comando permitido em /api/command
+type CommandDefinition struct {
+	Description string                  `json:"description"`
+	Command     string                  `json:"command"`
+	Args        []string                `json:"args"` // Aceita {{param}} como template
+	Params      map[string]CommandParam `json:"params"`
+}
+
+// compileCommandDefinitions valida as definições de comandos e compila seus padrões
+func compileCommandDefinitions(defs map[string]CommandDefinition) error {
+	for name, def := range defs {
+		if def.Command == "" {
+			return fmt.Errorf("comando '%s' sem 'command' definido", name)
+		}
+		for paramName, param := range def.Params {
+			if param.Pattern != "" {
+				re, err := regexp.Compile(`^(?:` + param.Pattern + `)$`)
+				if err != nil {
+					return fmt.Errorf("padrão inválido para o parâmetro '%s' do comando '%s': %w", paramName, name, err)
+				}
+				param.pattern = re
+			}
+			def.Params[paramName] = param
+		}
+		for _, arg := range def.Args {
+			for _, m := range commandTemplateVar.FindAllStringSubmatch(arg, -1) {
+				if _, ok := def.Params[m[1]]; !ok {
+					return fmt.Errorf("argumento '%s' do comando '%s' usa o parâmetro não declarado '%s'", arg, name, m[1])
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// validate verifica um valor contra as restrições do parâmetro
+func (p CommandParam) validate(value string) error {
+	if p.MaxLength > 0 && len(value) > p.MaxLength {
+		return fmt.Errorf("excede o tamanho máximo de %d", p.MaxLength)
+	}
+	if len(p.Enum) > 0 {
+		for _, allowed := range p.Enum {
+			if value == allowed {
+				return nil
+			}
+		}
+		return fmt.Errorf("valor não permitido (opções: %s)", strings.Join(p.Enum, ", "))
+	}
+	if p.pattern != nil {
+		if !p.pattern.MatchString(value) {
+			return fmt.Errorf("não corresponde ao padrão '%s'", p.Pattern)
+		}
+		return nil
+	}
+	if strings.HasPrefix(value, "-") || !defaultParamPattern.MatchString(value) {
+		return fmt.Errorf("contém caracteres não permitidos")
+	}
+	return nil
+}
+
+// buildArgs valida os parâmetros recebidos e monta a lista de argumentos.
+// Cada valor é substituído dentro de um único argumento, nunca passa por um shell.
+func (def CommandDefinition) buildArgs(params map[string]string) ([]string, error) {
+	for name := range params {
+		if _, ok := def.Params[name]; !ok {
+			return nil, fmt.Errorf("parâmetro desconhecido '%s'", name)
+		}
+	}
+
+	values := make(map[string]string, len(def.Params))
+	for name, param := range def.Params {
+		value, ok := params[name]
+		if !ok {
+			if param.Required {
+				return nil, fmt.Errorf("parâmetro obrigatório '%s' ausente", name)
+			}
+			values[name] = param.Default
+			continue
+		}
+		if err := param.validate(value); err != nil {
+			return nil, fmt.Errorf("parâmetro '%s' inválido: %w", name, err)
+		}
+		values[name] = value
+	}
+
+	args := make([]string, len(def.Args))
+	for i, arg := range def.Args {
+		args[i] = commandTemplateVar.ReplaceAllStringFunc(arg, func(m string) string {
+			return values[commandTemplateVar.FindStringSubmatch(m)[1]]
+		})
+	}
+	return args, nil
+}
+
+// isLoopbackRequest verifica se a requisição veio da própria máquina
+func isLoopbackRequest(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// commandAPIHandler cria o handler de /api/command, que executa apenas comandos nomeados.
+// O modo arbitrário (command + args) só é aceito com a opção insegura ativa e a partir do loopback.
+func commandAPIHandler(defs map[string]CommandDefinition, unsafeArbitrary bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+			return
+		}
+		var req struct {
+			Name    string            `json:"name"`
+			Params  map[string]string `json:"params"`
+			Command string            `json:"command"`
+			Args    []string          `json:"args"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+			http.Error(w, "Requisição inválida", http.StatusBadRequest)
+			return
+		}
+
+		if req.Name == "" {
+			if req.Command == "" {
+				http.Error(w, "Campo 'name' obrigatório", http.StatusBadRequest)
+				return
+			}
+			if !unsafeArbitrary || !isLoopbackRequest(r) {
+				log.Printf("Comando arbitrário recusado de %s: %s %v", r.RemoteAddr, req.Command, req.Args)
+				http.Error(w, "Execução de comandos arbitrários desabilitada. Use um comando nomeado.", http.StatusForbidden)
+				return
+			}
+			log.Printf("AVISO: executando comando arbitrário via API (modo inseguro): %s %v", req.Command, req.Args)
+			job := jobs.start("api", req.Command, req.Args)
+			writeJSON(w, http.StatusAccepted, job.info(false))
+			return
+		}
+
+		def, ok := defs[req.Name]
+		if !ok {
+			http.Error(w, fmt.Sprintf("Comando '%s' não encontrado", req.Name), http.StatusNotFound)
+			return
+		}
+		args, err := def.buildArgs(req.Params)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		log.Printf("Executando comando '%s' via API: %s %v", req.Name, def.Command, args)
+		job := jobs.start("api:"+req.Name, def.Command, args)
+		writeJSON(w, http.StatusAccepted, job.info(false))
+	}
+}
+
+// commandsListHandler lista os comandos nomeados disponíveis (GET /api/commands)
+func commandsListHandler(defs map[string]CommandDefinition) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+			return
+		}
+		type paramInfo struct {
+			Pattern   string   `json:"pattern,omitempty"`
+			Enum      []string `json:"enum,omitempty"`
+			Required  bool     `json:"required"`
+			Default   string   `json:"default,omitempty"`
+			MaxLength int      `json:"max_length,omitempty"`
+		}
+		type commandInfo struct {
+			Name        string               `json:"name"`
+			Description string               `json:"description,omitempty"`
+			Params      map[string]paramInfo `json:"params"`
+		}
+		list := make([]commandInfo, 0, len(defs))
+		for name, def := range defs {
+			info := commandInfo{Name: name, Description: def.Description, Params: map[string]paramInfo{}}
+			for paramName, p := range def.Params {
+				info.Params[paramName] = paramInfo{Pattern: p.Pattern, Enum: p.Enum, Required: p.Required, Default: p.Default, MaxLength: p.MaxLength}
+			}
+			list = append(list, info)
+		}
+		sort.Slice(list, func(a, b int) bool { return list[a].Name < list[b].Name })
+		writeJSON(w, http.StatusOK, list)
+	}
+}
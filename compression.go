@@ -0,0 +1,373 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// CompressionConfig ajusta a compressão habilitada por gzip_enabled
+type CompressionConfig struct {
+	Encodings    []string `json:"encodings"`     // Em ordem de preferência. Padrão: br, zstd, gzip
+	MinSize      int      `json:"min_size"`      // Respostas menores (em bytes) seguem sem compressão. Padrão: 1024
+	ContentTypes []string `json:"content_types"` // Prefixos de Content-Type comprimíveis. Padrão: texto, JS, JSON, SVG, etc.
+}
+
+// Tipos comprimidos quando content_types não é configurado (imagens, vídeos e fontes woff já são comprimidos)
+var defaultCompressibleTypes = []string{
+	"text/",
+	"application/javascript",
+	"application/x-javascript",
+	"application/json",
+	"application/manifest+json",
+	"application/xml",
+	"application/xhtml+xml",
+	"application/rss+xml",
+	"application/atom+xml",
+	"application/wasm",
+	"image/svg+xml",
+	"image/x-icon",
+	"font/ttf",
+	"font/otf",
+}
+
+// Extensões dos arquivos pré-comprimidos de cada codificação
+var precompressedExtensions = map[string]string{
+	"br":   ".br",
+	"zstd": ".zst",
+	"gzip": ".gz",
+}
+
+// normalizeCompressionConfig aplica os padrões e valida as codificações
+func normalizeCompressionConfig(cfg CompressionConfig) (CompressionConfig, error) {
+	if len(cfg.Encodings) == 0 {
+		cfg.Encodings = []string{"br", "zstd", "gzip"}
+	}
+	for i, encoding := range cfg.Encodings {
+		encoding = strings.ToLower(strings.TrimSpace(encoding))
+		if _, ok := precompressedExtensions[encoding]; !ok {
+			return cfg, fmt.Errorf("codificação inválida %q (use br, zstd ou gzip)", encoding)
+		}
+		cfg.Encodings[i] = encoding
+	}
+	if cfg.MinSize <= 0 {
+		cfg.MinSize = 1024
+	}
+	if len(cfg.ContentTypes) == 0 {
+		cfg.ContentTypes = defaultCompressibleTypes
+	}
+	return cfg, nil
+}
+
+// compressible verifica se o Content-Type está entre os configurados
+func (cfg CompressionConfig) compressible(contentType string) bool {
+	mediaType := strings.ToLower(strings.TrimSpace(strings.SplitN(contentType, ";", 2)[0]))
+	if mediaType == "" || mediaType == "text/event-stream" {
+		return false
+	}
+	for _, prefix := range cfg.ContentTypes {
+		if strings.HasPrefix(mediaType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// negotiateEncoding escolhe, entre as oferecidas, a codificação com maior q em Accept-Encoding.
+// Empates seguem a ordem de 'offered'; "" significa sem compressão.
+func negotiateEncoding(acceptEncoding string, offered []string) string {
+	qualities := make(map[string]float64)
+	wildcard := 0.0
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if ok && strings.EqualFold(strings.TrimSpace(key), "q") {
+				if parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		switch name {
+		case "*":
+			wildcard = q
+		case "x-gzip":
+			qualities["gzip"] = q
+		default:
+			qualities[name] = q
+		}
+	}
+
+	best, bestQ := "", 0.0
+	for _, encoding := range offered {
+		q, ok := qualities[encoding]
+		if !ok {
+			q = wildcard
+		}
+		if q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+	return best
+}
+
+// addVary adiciona Accept-Encoding ao cabeçalho Vary, sem repetir
+func addVary(header http.Header) {
+	for _, value := range header.Values("Vary") {
+		for _, field := range strings.Split(value, ",") {
+			if field = strings.TrimSpace(field); field == "*" || strings.EqualFold(field, "Accept-Encoding") {
+				return
+			}
+		}
+	}
+	header.Add("Vary", "Accept-Encoding")
+}
+
+// newEncoder cria o compressor da codificação escolhida
+func newEncoder(encoding string, w io.Writer) io.WriteCloser {
+	switch encoding {
+	case "br":
+		return brotli.NewWriterLevel(w, brotli.DefaultCompression)
+	case "zstd":
+		if encoder, err := zstd.NewWriter(w, zstd.WithEncoderConcurrency(1)); err == nil {
+			return encoder
+		}
+	}
+	return gzip.NewWriter(w)
+}
+
+// Estados do compressWriter
+const (
+	compressUndecided   = iota // Cabeçalhos ainda não enviados
+	compressBuffering          // Tamanho desconhecido: acumulando até min_size
+	compressActive             // Comprimindo
+	compressPassthrough        // Sem compressão
+)
+
+// compressWriter decide pela compressão ao ver os cabeçalhos da resposta: status, tipo,
+// Content-Encoding e tamanho. Sem Content-Length, acumula até min_size antes de decidir.
+type compressWriter struct {
+	http.ResponseWriter
+	cfg      CompressionConfig
+	encoding string // Codificação negociada ("" se o cliente não aceita nenhuma)
+	head     bool
+
+	state   int
+	status  int
+	buf     []byte
+	encoder io.WriteCloser
+}
+
+func (w *compressWriter) WriteHeader(statusCode int) {
+	if w.state != compressUndecided {
+		return
+	}
+	if statusCode < http.StatusOK {
+		w.ResponseWriter.WriteHeader(statusCode) // 1xx informativo: a resposta final ainda virá
+		return
+	}
+	w.status = statusCode
+	header := w.ResponseWriter.Header()
+	if header.Get("Content-Encoding") != "" || !w.cfg.compressible(header.Get("Content-Type")) {
+		w.passthrough()
+		return
+	}
+	addVary(header)
+	if w.encoding == "" || w.head || statusCode == http.StatusNoContent || statusCode == http.StatusPartialContent || statusCode == http.StatusNotModified {
+		w.passthrough()
+		return
+	}
+	if length, err := strconv.Atoi(header.Get("Content-Length")); err == nil {
+		if length < w.cfg.MinSize {
+			w.passthrough()
+		} else {
+			w.startCompression()
+		}
+		return
+	}
+	w.state = compressBuffering
+}
+
+func (w *compressWriter) passthrough() {
+	w.state = compressPassthrough
+	w.ResponseWriter.WriteHeader(w.status)
+}
+
+func (w *compressWriter) startCompression() {
+	header := w.ResponseWriter.Header()
+	header.Set("Content-Encoding", w.encoding)
+	header.Del("Content-Length")
+	header.Del("Accept-Ranges")
+	// O corpo comprimido é outra representação: o ETag forte do arquivo passa a ser fraco
+	if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		header.Set("ETag", "W/"+etag)
+	}
+	w.state = compressActive
+	w.ResponseWriter.WriteHeader(w.status)
+	w.encoder = newEncoder(w.encoding, w.ResponseWriter)
+}
+
+func (w *compressWriter) Write(p []byte) (int, error) {
+	if w.state == compressUndecided {
+		if header := w.ResponseWriter.Header(); header.Get("Content-Type") == "" {
+			header.Set("Content-Type", http.DetectContentType(p))
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	switch w.state {
+	case compressBuffering:
+		w.buf = append(w.buf, p...)
+		if len(w.buf) < w.cfg.MinSize {
+			return len(p), nil
+		}
+		w.startCompression()
+		buf := w.buf
+		w.buf = nil
+		if _, err := w.encoder.Write(buf); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	case compressActive:
+		return w.encoder.Write(p)
+	default:
+		return w.ResponseWriter.Write(p)
+	}
+}
+
+// finish envia o que ficou acumulado (sem compressão, se não chegou a min_size) e fecha o compressor
+func (w *compressWriter) finish() {
+	if w.state == compressUndecided {
+		w.WriteHeader(http.StatusOK)
+	}
+	switch w.state {
+	case compressBuffering:
+		w.passthrough()
+		if len(w.buf) > 0 {
+			w.ResponseWriter.Write(w.buf)
+		}
+		w.buf = nil
+	case compressActive:
+		w.encoder.Close()
+	}
+}
+
+func (w *compressWriter) Flush() {
+	if w.state == compressUndecided {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.state == compressBuffering {
+		// Streaming: não dá para esperar min_size
+		w.startCompression()
+		w.encoder.Write(w.buf)
+		w.buf = nil
+	}
+	if w.state == compressActive {
+		if flusher, ok := w.encoder.(interface{ Flush() error }); ok {
+			flusher.Flush()
+		}
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return hijack(w.ResponseWriter)
+}
+
+// compressMiddleware comprime as respostas com a codificação negociada (br, zstd ou gzip)
+func compressMiddleware(enabled bool, cfg CompressionConfig, next http.Handler) http.Handler {
+	if !enabled {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cw := &compressWriter{
+			ResponseWriter: w,
+			cfg:            cfg,
+			encoding:       negotiateEncoding(r.Header.Get("Accept-Encoding"), cfg.Encodings),
+			head:           r.Method == http.MethodHead,
+		}
+		defer cw.finish()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// precompressedMiddleware serve arquivo.br, arquivo.zst ou arquivo.gz no lugar do arquivo quando
+// existirem e o cliente aceitar a codificação. Páginas HTML ficam de fora, pois recebem a injeção
+// do live reload (e são comprimidas na hora).
+func precompressedMiddleware(enabled bool, serveDir string, cfg CompressionConfig, next http.Handler) http.Handler {
+	if !enabled {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		urlPath := path.Clean("/" + r.URL.Path)
+		contentType := mime.TypeByExtension(path.Ext(urlPath))
+		if (r.Method != http.MethodGet && r.Method != http.MethodHead) || strings.HasSuffix(r.URL.Path, "/") || strings.HasPrefix(contentType, "text/html") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		file := filepath.Join(serveDir, filepath.FromSlash(urlPath))
+		if info, err := os.Stat(file); err != nil || info.IsDir() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		var available []string
+		for _, encoding := range cfg.Encodings {
+			if info, err := os.Stat(file + precompressedExtensions[encoding]); err == nil && !info.IsDir() {
+				available = append(available, encoding)
+			}
+		}
+		if len(available) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		addVary(w.Header())
+		encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"), available)
+		if encoding == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		sidecar := file + precompressedExtensions[encoding]
+		f, err := os.Open(sidecar)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		defer f.Close()
+		info, err := f.Stat()
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if contentType != "" {
+			w.Header().Set("Content-Type", contentType)
+		}
+		w.Header().Set("Content-Encoding", encoding)
+		// No modo preview, o ETag passa a ser o do arquivo comprimido servido
+		if w.Header().Get("ETag") != "" {
+			if etag, err := fileETag(sidecar, info); err == nil {
+				w.Header().Set("ETag", `"`+etag+`"`)
+			}
+		}
+		http.ServeContent(w, r, urlPath, info.ModTime(), f)
+	})
+}
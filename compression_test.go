@@ -0,0 +1,33 @@
+package main
+
+import "testing"
+
+func TestNegotiateEncoding(t *testing.T) {
+	offered := []string{"br", "zstd", "gzip"}
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{"", ""},
+		{"identity", ""},
+		{"gzip", "gzip"},
+		{"x-gzip", "gzip"},
+		{"gzip, deflate, br", "br"},
+		{"gzip;q=1.0, br;q=0.5", "gzip"},
+		{"GZIP; Q=0.8, zstd;q=0.9", "zstd"},
+		{"br;q=0, gzip", "gzip"},
+		{"*", "br"},
+		{"*;q=0.5, br;q=0", "zstd"},
+		{"gzip;q=0.5, *;q=0.7", "br"},
+		{"br;q=abc", "br"}, // q inválido vale 1
+		{"deflate", ""},
+	}
+	for _, tt := range tests {
+		if got := negotiateEncoding(tt.accept, offered); got != tt.want {
+			t.Errorf("negotiateEncoding(%q) = %q, esperava %q", tt.accept, got, tt.want)
+		}
+	}
+	if got := negotiateEncoding("br, gzip", []string{"gzip", "br"}); got != "gzip" {
+		t.Errorf("empate deve seguir a ordem oferecida, obtido %q", got)
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultConsoleHistorySize = 200
+
+// ConsoleConfig configura o encaminhamento do console do navegador para o servidor
+type ConsoleConfig struct {
+	Enabled       bool     `json:"enabled"`
+	Levels        []string `json:"levels"`          // Padrão: log, info, warn, error, debug
+	HistorySize   int      `json:"history_size"`    // Entradas mantidas para /api/console
+	NotifyOnError bool     `json:"notify_on_error"` // Dispara notification_webhook_url em erros
+}
+
+var defaultConsoleLevels = []string{"log", "info", "warn", "error", "debug"}
+
+// consoleEntry é uma mensagem de console (ou erro não tratado) recebida de um navegador
+type consoleEntry struct {
+	Time      time.Time `json:"time"`
+	ClientID  string    `json:"client_id"`
+	UserAgent string    `json:"user_agent"`
+	URL       string    `json:"url"`
+	Level     string    `json:"level"`
+	Source    string    `json:"source"` // console, error ou unhandledrejection
+	Message   string    `json:"message"`
+	Stack     string    `json:"stack,omitempty"`
+}
+
+// consoleLog guarda as mensagens recentes do console dos navegadores
+type consoleLog struct {
+	mu              sync.Mutex
+	config          ConsoleConfig
+	notificationURL string
+	entries         []consoleEntry
+}
+
+// Histórico global do console, configurado em main
+var browserConsole = &consoleLog{}
+
+func (l *consoleLog) configure(cfg ConsoleConfig, notificationURL string) {
+	if len(cfg.Levels) == 0 {
+		cfg.Levels = defaultConsoleLevels
+	}
+	if cfg.HistorySize <= 0 {
+		cfg.HistorySize = defaultConsoleHistorySize
+	}
+	l.mu.Lock()
+	l.config = cfg
+	l.notificationURL = notificationURL
+	l.mu.Unlock()
+}
+
+// configMessage informa ao cliente se deve encaminhar o console e quais níveis
+func (l *consoleLog) configMessage() []byte {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	message, _ := json.Marshal(map[string]interface{}{
+		"type":    "console-config",
+		"enabled": l.config.Enabled,
+		"levels":  l.config.Levels,
+	})
+	return message
+}
+
+// record registra uma mensagem recebida de um cliente
+func (l *consoleLog) record(c *Client, data []byte) {
+	var msg struct {
+		Level   string `json:"level"`
+		Source  string `json:"source"`
+		Message string `json:"message"`
+		Stack   string `json:"stack"`
+		URL     string `json:"url"`
+	}
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return
+	}
+
+	l.mu.Lock()
+	if !l.config.Enabled {
+		l.mu.Unlock()
+		return
+	}
+	info := c.info()
+	entry := consoleEntry{
+		Time:      time.Now(),
+		ClientID:  info.ID,
+		UserAgent: info.UserAgent,
+		URL:       msg.URL,
+		Level:     msg.Level,
+		Source:    msg.Source,
+		Message:   msg.Message,
+		Stack:     msg.Stack,
+	}
+	if entry.URL == "" {
+		entry.URL = info.URL
+	}
+	l.entries = append(l.entries, entry)
+	if excess := len(l.entries) - l.config.HistorySize; excess > 0 {
+		l.entries = append(l.entries[:0:0], l.entries[excess:]...)
+	}
+	notify := l.config.NotifyOnError && entry.Level == "error"
+	notificationURL := l.notificationURL
+	l.mu.Unlock()
+
+	if entry.Stack != "" && !strings.Contains(entry.Message, entry.Stack) {
+		log.Printf("[navegador %s] [%s] %s: %s\n%s", entry.ClientID, entry.Level, entry.URL, entry.Message, entry.Stack)
+	} else {
+		log.Printf("[navegador %s] [%s] %s: %s", entry.ClientID, entry.Level, entry.URL, entry.Message)
+	}
+
+	if notify {
+		go sendNotificationWebhook(notificationURL, map[string]string{
+			"event_type": "browser_error",
+			"client_id":  entry.ClientID,
+			"user_agent": entry.UserAgent,
+			"url":        entry.URL,
+			"source":     entry.Source,
+			"message":    entry.Message,
+			"stack":      entry.Stack,
+			"timestamp":  entry.Time.Format(time.RFC3339),
+		})
+	}
+}
+
+// handleConsoleAPI lista (GET /api/console?level=error&client=id&limit=50) ou limpa (DELETE) o histórico
+func handleConsoleAPI(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		q := r.URL.Query()
+		level, clientID := q.Get("level"), q.Get("client")
+		limit, _ := strconv.Atoi(q.Get("limit"))
+
+		browserConsole.mu.Lock()
+		entries := []consoleEntry{}
+		for _, entry := range browserConsole.entries {
+			if (level == "" || entry.Level == level) && (clientID == "" || entry.ClientID == clientID) {
+				entries = append(entries, entry)
+			}
+		}
+		browserConsole.mu.Unlock()
+
+		if limit > 0 && len(entries) > limit {
+			entries = entries[len(entries)-limit:]
+		}
+		writeJSON(w, http.StatusOK, entries)
+	case http.MethodDelete:
+		browserConsole.mu.Lock()
+		browserConsole.entries = nil
+		browserConsole.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+	}
+}
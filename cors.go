@@ -0,0 +1,207 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// CORSPolicy define os cabeçalhos CORS de um conjunto de caminhos
+type CORSPolicy struct {
+	Disabled         bool     `json:"disabled"`             // Não trata CORS (nem preflight): a resposta segue como o handler gerou
+	AllowedOrigins   []string `json:"allowed_origins"`      // "*", "https://app.com" ou "https://*.example.com". Padrão: "*"
+	OriginRegex      []string `json:"allowed_origin_regex"` // Expressões regulares para a origem inteira
+	AllowCredentials bool     `json:"allow_credentials"`    // Reflete a origem e envia Allow-Credentials; exige origens explícitas
+	AllowedMethods   []string `json:"allowed_methods"`
+	AllowedHeaders   []string `json:"allowed_headers"` // "*" reflete Access-Control-Request-Headers
+	ExposedHeaders   []string `json:"exposed_headers"`
+	MaxAge           int      `json:"max_age"` // Segundos de cache do preflight; 0 não envia
+
+	originRegex []*regexp.Regexp
+}
+
+// CORSOverride substitui a política geral nos caminhos indicados (globs)
+type CORSOverride struct {
+	Paths []string `json:"paths"`
+	CORSPolicy
+}
+
+// CORSConfig é a seção "cors" da configuração
+type CORSConfig struct {
+	CORSPolicy
+	Overrides        []CORSOverride `json:"overrides"`
+	ProxyPassthrough bool           `json:"proxy_passthrough"` // Caminhos de proxy_rules repassam os cabeçalhos CORS do backend
+}
+
+// prepare aplica os padrões e compila as expressões regulares
+func (p *CORSPolicy) prepare() error {
+	if p.AllowCredentials && !p.Disabled {
+		// Com credenciais a origem é refletida: sem uma lista explícita, qualquer site leria as respostas autenticadas
+		if len(p.AllowedOrigins) == 0 && len(p.OriginRegex) == 0 {
+			return fmt.Errorf("allow_credentials exige allowed_origins ou allowed_origin_regex explícitos")
+		}
+		for _, origin := range p.AllowedOrigins {
+			if origin == "*" {
+				return fmt.Errorf("allow_credentials não pode ser usado com a origem \"*\"")
+			}
+		}
+	}
+	if len(p.AllowedOrigins) == 0 && len(p.OriginRegex) == 0 {
+		p.AllowedOrigins = []string{"*"}
+	}
+	if len(p.AllowedMethods) == 0 {
+		p.AllowedMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+	}
+	if len(p.AllowedHeaders) == 0 {
+		p.AllowedHeaders = []string{"Content-Type", "Authorization"}
+	}
+	p.originRegex = nil
+	for _, pattern := range p.OriginRegex {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("allowed_origin_regex %q inválida: %v", pattern, err)
+		}
+		p.originRegex = append(p.originRegex, re)
+	}
+	return nil
+}
+
+func (c *CORSConfig) prepare() error {
+	if err := c.CORSPolicy.prepare(); err != nil {
+		return err
+	}
+	for i := range c.Overrides {
+		if len(c.Overrides[i].Paths) == 0 {
+			return fmt.Errorf("override %d: informe 'paths'", i)
+		}
+		if err := c.Overrides[i].CORSPolicy.prepare(); err != nil {
+			return fmt.Errorf("override %d: %v", i, err)
+		}
+	}
+	return nil
+}
+
+// policyFor retorna a política do caminho: o primeiro override que corresponde ou a geral
+func (c *CORSConfig) policyFor(urlPath string) *CORSPolicy {
+	for i := range c.Overrides {
+		for _, pattern := range c.Overrides[i].Paths {
+			if matchGlob(pattern, urlPath) {
+				return &c.Overrides[i].CORSPolicy
+			}
+		}
+	}
+	return &c.CORSPolicy
+}
+
+// matchOriginPattern compara a origem com um valor exato ou com curinga de subdomínio (https://*.example.com)
+func matchOriginPattern(pattern, origin string) bool {
+	if strings.EqualFold(pattern, origin) {
+		return true
+	}
+	prefix, suffix, ok := strings.Cut(strings.ToLower(pattern), "*.")
+	if !ok {
+		return false
+	}
+	origin = strings.ToLower(origin)
+	if !strings.HasPrefix(origin, prefix) || !strings.HasSuffix(origin, "."+suffix) {
+		return false
+	}
+	subdomain := origin[len(prefix) : len(origin)-len(suffix)-1]
+	return subdomain != "" && !strings.ContainsAny(subdomain, "/:@")
+}
+
+// allowOrigin informa se a origem é aceita e se a política aceita qualquer origem ("*")
+func (p *CORSPolicy) allowOrigin(origin string) (allowed, any bool) {
+	for _, pattern := range p.AllowedOrigins {
+		if pattern == "*" {
+			return true, true
+		}
+		if origin != "" && matchOriginPattern(pattern, origin) {
+			return true, false
+		}
+	}
+	for _, re := range p.originRegex {
+		if origin != "" && re.MatchString(origin) {
+			return true, false
+		}
+	}
+	return false, false
+}
+
+// setOriginHeaders define Allow-Origin e Allow-Credentials. Com credenciais, o navegador
+// recusa "*", então a origem é refletida (com Vary: Origin).
+func (p *CORSPolicy) setOriginHeaders(header http.Header, origin string) bool {
+	allowed, any := p.allowOrigin(origin)
+	if any && !p.AllowCredentials {
+		header.Set("Access-Control-Allow-Origin", "*")
+		return true
+	}
+	header.Add("Vary", "Origin")
+	if !allowed || origin == "" {
+		header.Del("Access-Control-Allow-Origin")
+		header.Del("Access-Control-Allow-Credentials")
+		return false
+	}
+	header.Set("Access-Control-Allow-Origin", origin)
+	if p.AllowCredentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+	return true
+}
+
+// preflight responde a uma requisição OPTIONS com Access-Control-Request-Method
+func (p *CORSPolicy) preflight(w http.ResponseWriter, r *http.Request) {
+	header := w.Header()
+	header.Add("Vary", "Access-Control-Request-Method")
+	header.Add("Vary", "Access-Control-Request-Headers")
+	if p.setOriginHeaders(header, r.Header.Get("Origin")) {
+		header.Set("Access-Control-Allow-Methods", strings.Join(p.AllowedMethods, ", "))
+		allowedHeaders := strings.Join(p.AllowedHeaders, ", ")
+		if allowedHeaders == "*" && p.AllowCredentials {
+			// Com credenciais, "*" é lido literalmente: reflete os cabeçalhos pedidos
+			allowedHeaders = r.Header.Get("Access-Control-Request-Headers")
+		}
+		if allowedHeaders != "" {
+			header.Set("Access-Control-Allow-Headers", allowedHeaders)
+		}
+		if p.MaxAge > 0 {
+			header.Set("Access-Control-Max-Age", strconv.Itoa(p.MaxAge))
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// corsMiddleware aplica a política CORS do caminho. Os cabeçalhos são definidos logo antes
+// do envio da resposta, substituindo os que vierem de um backend (exceto com proxy_passthrough).
+func corsMiddleware(cfg CORSConfig, proxyRules []ProxyRule, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if cfg.ProxyPassthrough {
+			for _, rule := range proxyRules {
+				if strings.HasPrefix(r.URL.Path, rule.Path) {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+		}
+		policy := cfg.policyFor(r.URL.Path)
+		if policy.Disabled {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			policy.preflight(w, r)
+			return
+		}
+		origin := r.Header.Get("Origin")
+		hw := &hookWriter{ResponseWriter: w, before: func(status int) {
+			header := w.Header()
+			if policy.setOriginHeaders(header, origin) && len(policy.ExposedHeaders) > 0 {
+				header.Set("Access-Control-Expose-Headers", strings.Join(policy.ExposedHeaders, ", "))
+			}
+		}}
+		defer hw.finish()
+		next.ServeHTTP(hw, r)
+	})
+}
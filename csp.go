@@ -0,0 +1,257 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Modos de injeção em páginas com Content-Security-Policy
+const (
+	cspModeNonce    = "nonce"    // Adiciona um nonce às tags injetadas e à política (padrão)
+	cspModeExternal = "external" // Não altera a política; injeta apenas scripts e estilos externos
+)
+
+var (
+	metaTagPattern   = regexp.MustCompile(`(?is)<meta\b[^>]*>`)
+	httpEquivPattern = regexp.MustCompile(`(?i)\bhttp-equiv\s*=\s*["']?content-security-policy["'\s/>]`)
+	metaContentAttr  = regexp.MustCompile(`(?is)(\bcontent\s*=\s*)(?:"([^"]*)"|'([^']*)')`)
+)
+
+// Escapa a política para o atributo content (entre aspas duplas)
+var cspAttrEscaper = strings.NewReplacer("&", "&amp;", `"`, "&quot;")
+
+var cspHeaders = []string{"Content-Security-Policy", "Content-Security-Policy-Report-Only"}
+
+// cspDirective é uma diretiva de política: nome e lista de fontes
+type cspDirective struct {
+	name    string
+	sources []string
+}
+
+func parseCSP(policy string) []cspDirective {
+	var directives []cspDirective
+	for _, part := range strings.Split(policy, ";") {
+		fields := strings.Fields(part)
+		if len(fields) == 0 {
+			continue
+		}
+		directives = append(directives, cspDirective{name: strings.ToLower(fields[0]), sources: fields[1:]})
+	}
+	return directives
+}
+
+func formatCSP(directives []cspDirective) string {
+	parts := make([]string, 0, len(directives))
+	for _, d := range directives {
+		parts = append(parts, strings.TrimSpace(d.name+" "+strings.Join(d.sources, " ")))
+	}
+	return strings.Join(parts, "; ")
+}
+
+func hasSource(sources []string, prefix string) bool {
+	for _, s := range sources {
+		if strings.HasPrefix(strings.ToLower(s), prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func addSource(sources []string, source string) []string {
+	result := sources[:0:0]
+	for _, s := range sources {
+		if s == source {
+			return sources
+		}
+		if strings.ToLower(s) != "'none'" {
+			result = append(result, s)
+		}
+	}
+	return append(result, source)
+}
+
+// cspRewrite descreve o que precisa ser liberado na política da página
+type cspRewrite struct {
+	nonce       string
+	connectSrcs []string // Origens do WebSocket e da própria página
+	inlineStyle bool     // Há um <style> injetado
+}
+
+// allowElement libera scripts ou estilos injetados em um grupo de diretivas (ex: script-src-elem, script-src).
+// Se a diretiva já aceita 'unsafe-inline' sem nonce/hash, adicionar um nonce desativaria o
+// 'unsafe-inline' da própria página; nesse caso basta liberar 'self' para os arquivos externos.
+func (rw cspRewrite) allowElement(directives []cspDirective, names []string) []cspDirective {
+	found := false
+	for i := range directives {
+		for _, name := range names {
+			if directives[i].name != name {
+				continue
+			}
+			found = true
+			sources := directives[i].sources
+			if hasSource(sources, "'unsafe-inline'") && !hasSource(sources, "'nonce-") && !hasSource(sources, "'sha") && !hasSource(sources, "'strict-dynamic'") {
+				directives[i].sources = addSource(sources, "'self'")
+			} else {
+				directives[i].sources = addSource(sources, "'nonce-"+rw.nonce+"'")
+			}
+		}
+	}
+	if !found {
+		// Sem diretiva específica: copia default-src (se houver) para não afrouxar os outros tipos
+		for _, d := range directives {
+			if d.name == "default-src" {
+				created := cspDirective{name: names[len(names)-1], sources: append([]string(nil), d.sources...)}
+				directives = append(directives, created)
+				return rw.allowElement(directives, names)
+			}
+		}
+	}
+	return directives
+}
+
+func (rw cspRewrite) allowConnect(directives []cspDirective) []cspDirective {
+	for i := range directives {
+		if directives[i].name == "connect-src" {
+			for _, src := range rw.connectSrcs {
+				directives[i].sources = addSource(directives[i].sources, src)
+			}
+			return directives
+		}
+	}
+	for _, d := range directives {
+		if d.name == "default-src" {
+			directives = append(directives, cspDirective{name: "connect-src", sources: append([]string(nil), d.sources...)})
+			return rw.allowConnect(directives)
+		}
+	}
+	return directives
+}
+
+// apply reescreve uma política para aceitar as injeções do brhttp
+func (rw cspRewrite) apply(policy string) string {
+	directives := parseCSP(policy)
+	directives = rw.allowElement(directives, []string{"script-src-elem", "script-src"})
+	if rw.inlineStyle {
+		directives = rw.allowElement(directives, []string{"style-src-elem", "style-src"})
+	}
+	directives = rw.allowConnect(directives)
+	return formatCSP(directives)
+}
+
+// cspProblems lista o que impede as injeções de funcionar. Com 'external', a política não é
+// alterada, então também verifica se ela já aceita o script e a conexão do cliente.
+func cspProblems(policy string, external bool) []string {
+	var problems []string
+	directives := parseCSP(policy)
+	lookup := func(names ...string) (cspDirective, bool) {
+		for _, name := range names {
+			for _, d := range directives {
+				if d.name == name {
+					return d, true
+				}
+			}
+		}
+		return cspDirective{}, false
+	}
+	if d, ok := lookup("script-src-elem", "script-src", "default-src"); ok && external && (!hasSource(d.sources, "'self'") || hasSource(d.sources, "'strict-dynamic'")) {
+		problems = append(problems, d.name+" não permite o script do cliente ('self')")
+	}
+	if d, ok := lookup("connect-src", "default-src"); ok && external && !hasSource(d.sources, "'self'") && !hasSource(d.sources, "ws") {
+		problems = append(problems, d.name+" não permite a conexão de live reload")
+	}
+	if d, ok := lookup("sandbox"); ok && !hasSource(d.sources, "allow-scripts") {
+		problems = append(problems, "sandbox sem allow-scripts")
+	}
+	return problems
+}
+
+// headerCSP retorna as políticas enviadas em cabeçalhos
+func headerCSP(header http.Header) []string {
+	var policies []string
+	for _, name := range cspHeaders {
+		policies = append(policies, header.Values(name)...)
+	}
+	return policies
+}
+
+// metaCSP retorna as políticas declaradas em <meta http-equiv="Content-Security-Policy">
+func metaCSP(body []byte) []string {
+	var policies []string
+	for _, tag := range metaTagPattern.FindAll(body, -1) {
+		if !httpEquivPattern.Match(tag) {
+			continue
+		}
+		if m := metaContentAttr.FindSubmatch(tag); m != nil {
+			policies = append(policies, html.UnescapeString(string(m[2])+string(m[3])))
+		}
+	}
+	return policies
+}
+
+// rewriteHeaderCSP aplica a reescrita nos cabeçalhos de CSP
+func rewriteHeaderCSP(header http.Header, rw cspRewrite) {
+	for _, name := range cspHeaders {
+		values := header.Values(name)
+		if len(values) == 0 {
+			continue
+		}
+		header.Del(name)
+		for _, policy := range values {
+			header.Add(name, rw.apply(policy))
+		}
+	}
+}
+
+// rewriteMetaCSP aplica a reescrita nas tags <meta> de CSP
+func rewriteMetaCSP(body []byte, rw cspRewrite) []byte {
+	return metaTagPattern.ReplaceAllFunc(body, func(tag []byte) []byte {
+		if !httpEquivPattern.Match(tag) {
+			return tag
+		}
+		return metaContentAttr.ReplaceAllFunc(tag, func(attr []byte) []byte {
+			m := metaContentAttr.FindSubmatch(attr)
+			policy := rw.apply(html.UnescapeString(string(m[2]) + string(m[3])))
+			return []byte(fmt.Sprintf(`%s"%s"`, m[1], cspAttrEscaper.Replace(policy)))
+		})
+	})
+}
+
+func newCSPNonce() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+// liveReloadConnectSources são as origens usadas pelo cliente para falar com o servidor.
+// Com public_base_url absoluta, a origem dela também é liberada.
+func liveReloadConnectSources(r *http.Request) []string {
+	sources := []string{"'self'", "ws://" + r.Host}
+	if r.TLS != nil {
+		sources[1] = "wss://" + r.Host
+	}
+	if u, err := url.Parse(publicBaseURL); err == nil && u.IsAbs() {
+		ws := "ws://"
+		if u.Scheme == "https" {
+			ws = "wss://"
+		}
+		sources = append(sources, u.Scheme+"://"+u.Host, ws+u.Host)
+	}
+	return sources
+}
+
+// Avisos de CSP já emitidos, para não repetir a cada recarga
+var cspWarned sync.Map
+
+func warnCSP(urlPath, problem string) {
+	if _, loaded := cspWarned.LoadOrStore(urlPath+"\x00"+problem, true); !loaded {
+		log.Printf("⚠️  CSP em %s impede o live reload: %s", urlPath, problem)
+	}
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Referências locais em páginas HTML: atributos comuns de inclusão e includes estilo SSI
+var (
+	htmlReferencePattern = regexp.MustCompile(`(?i)\b(?:src|href|data-src|data-include|hx-get|include)\s*=\s*["']([^"'#?]+)`)
+	ssiIncludePattern    = regexp.MustCompile(`(?i)<!--#include\s+(?:file|virtual)\s*=\s*["']([^"']+)["']`)
+)
+
+const maxDependencyScanDepth = 3
+
+// dependencyGraph registra quais recursos cada página (ou recurso) carrega.
+// As arestas vêm do cabeçalho Referer, da varredura de HTML e dos relatos do cliente.
+type dependencyGraph struct {
+	mu          sync.Mutex
+	serveDir    string
+	spaFallback bool
+	edges       map[string]map[string]bool
+	scanned     map[string]bool
+}
+
+// Grafo global de dependências, configurado em main
+var dependencies = newDependencyGraph("", false)
+
+func newDependencyGraph(serveDir string, spaFallback bool) *dependencyGraph {
+	return &dependencyGraph{
+		serveDir:    serveDir,
+		spaFallback: spaFallback,
+		edges:       make(map[string]map[string]bool),
+		scanned:     make(map[string]bool),
+	}
+}
+
+func (g *dependencyGraph) addEdge(from, to string) {
+	if from == to {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.addEdgeLocked(from, to)
+}
+
+func (g *dependencyGraph) addEdgeLocked(from, to string) {
+	deps, ok := g.edges[from]
+	if !ok {
+		deps = make(map[string]bool)
+		g.edges[from] = deps
+	}
+	deps[to] = true
+}
+
+// reaches verifica se 'target' é alcançável a partir de alguma das raízes
+func (g *dependencyGraph) reaches(roots []string, target string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	visited := make(map[string]bool)
+	queue := append([]string(nil), roots...)
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if current == target {
+			return true
+		}
+		if visited[current] {
+			continue
+		}
+		visited[current] = true
+		for dep := range g.edges[current] {
+			queue = append(queue, dep)
+		}
+	}
+	return false
+}
+
+// resolveDocument descobre qual arquivo HTML atende uma URL de página
+func (g *dependencyGraph) resolveDocument(pagePath string) string {
+	pagePath = path.Clean("/" + pagePath)
+	candidates := []string{pagePath, pagePath + ".html", path.Join(pagePath, "index.html")}
+	for _, candidate := range candidates {
+		info, err := os.Stat(filepath.Join(g.serveDir, filepath.FromSlash(candidate)))
+		if err == nil && !info.IsDir() {
+			return candidate
+		}
+	}
+	if g.spaFallback {
+		return "/index.html"
+	}
+	return pagePath
+}
+
+// scanDocument registra as referências locais de um arquivo HTML (e de seus parciais)
+func (g *dependencyGraph) scanDocument(doc string, depth int) {
+	if depth > maxDependencyScanDepth {
+		return
+	}
+	ext := strings.ToLower(path.Ext(doc))
+	if ext != ".html" && ext != ".htm" && ext != ".shtml" {
+		return
+	}
+	g.mu.Lock()
+	if g.scanned[doc] {
+		g.mu.Unlock()
+		return
+	}
+	g.scanned[doc] = true
+	g.mu.Unlock()
+
+	content, err := os.ReadFile(filepath.Join(g.serveDir, filepath.FromSlash(doc)))
+	if err != nil {
+		return
+	}
+	var refs []string
+	for _, pattern := range []*regexp.Regexp{htmlReferencePattern, ssiIncludePattern} {
+		for _, m := range pattern.FindAllSubmatch(content, -1) {
+			if ref := resolveLocalReference(doc, string(m[1])); ref != "" {
+				refs = append(refs, ref)
+			}
+		}
+	}
+	for _, ref := range refs {
+		g.addEdge(doc, ref)
+		g.scanDocument(ref, depth+1)
+	}
+}
+
+// invalidate descarta as arestas de um arquivo alterado para que seja varrido novamente
+func (g *dependencyGraph) invalidate(urlPath string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.scanned[urlPath] {
+		delete(g.scanned, urlPath)
+		delete(g.edges, urlPath)
+	}
+}
+
+// resolveLocalReference transforma uma referência de um documento em caminho absoluto.
+// Retorna "" para URLs externas.
+func resolveLocalReference(from, ref string) string {
+	ref = strings.TrimSpace(ref)
+	if ref == "" || strings.HasPrefix(ref, "//") || strings.Contains(ref, ":") {
+		return ""
+	}
+	if strings.HasPrefix(ref, "/") {
+		return path.Clean(ref)
+	}
+	return path.Clean(path.Join(path.Dir(from), ref))
+}
+
+// localPathFromURL extrai o caminho de uma URL se ela for da mesma origem que a requisição
+func localPathFromURL(raw, host string) string {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Host != "" && u.Host != host) {
+		return ""
+	}
+	return path.Clean("/" + u.Path)
+}
+
+// dependencyTrackingMiddleware registra o Referer de cada requisição como dependência
+func dependencyTrackingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if referer := r.Header.Get("Referer"); referer != "" && r.Method == http.MethodGet {
+			if from := localPathFromURL(referer, r.Host); from != "" {
+				dependencies.addEdge(from, path.Clean("/"+r.URL.Path))
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// clientReport é uma mensagem enviada pelo cliente de live reload
+type clientReport struct {
+	Type      string          `json:"type"`
+	Event     string          `json:"event"`
+	URL       string          `json:"url"`
+	Resources []string        `json:"resources"`
+	Topics    []string        `json:"topics"`
+	Viewport  *clientViewport `json:"viewport"`
+}
+
+// handleClientMessage processa mensagens recebidas do navegador
+func (c *Client) handleClientMessage(data []byte, host string) {
+	var report clientReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return
+	}
+	if report.Viewport != nil {
+		c.mu.Lock()
+		c.viewport = *report.Viewport
+		c.mu.Unlock()
+	}
+	switch report.Type {
+	case "hello":
+		c.setPage(report.URL)
+		c.addResources(report.Resources, host)
+		c.subscribe(report.Topics)
+		c.queue(interactionSync.configMessage())
+		c.queue(browserConsole.configMessage())
+	case "navigate":
+		c.setPage(report.URL)
+		c.addResources(report.Resources, host)
+	case "deps":
+		c.addResources(report.Resources, host)
+	case "sync":
+		relaySyncEvent(c, report.Event, data)
+	case "console":
+		browserConsole.record(c, data)
+	case "subscribe":
+		c.subscribe(report.Topics)
+	case "unsubscribe":
+		c.unsubscribe(report.Topics)
+	}
+}
+
+// setPage registra a URL aberta no cliente e resolve o documento HTML correspondente
+func (c *Client) setPage(pageURL string) {
+	page := localPathFromURL(pageURL, c.host)
+	if page == "" {
+		return
+	}
+	doc := dependencies.resolveDocument(page)
+	dependencies.scanDocument(doc, 0)
+	c.mu.Lock()
+	c.url = pageURL
+	c.page = page
+	c.document = doc
+	c.reported = true
+	c.mu.Unlock()
+}
+
+func (c *Client) addResources(resources []string, host string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.deps == nil {
+		c.deps = make(map[string]bool)
+	}
+	for _, resource := range resources {
+		if p := localPathFromURL(resource, host); p != "" {
+			c.deps[p] = true
+		}
+	}
+}
+
+// dependsOn verifica se a página aberta neste cliente depende do arquivo alterado.
+// Clientes que ainda não relataram sua página recebem todas as mensagens.
+func (c *Client) dependsOn(urlPath string) bool {
+	c.mu.Lock()
+	if !c.reported {
+		c.mu.Unlock()
+		return true
+	}
+	roots := []string{c.page, c.document}
+	for dep := range c.deps {
+		roots = append(roots, dep)
+	}
+	c.mu.Unlock()
+	return dependencies.reaches(roots, urlPath)
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EnvConfig expõe variáveis de arquivos .env às páginas como window.__ENV__
+type EnvConfig struct {
+	Files      []string `json:"files"`      // Ex: [".env", ".env.local"]; os últimos têm prioridade
+	Prefixes   []string `json:"prefixes"`   // Apenas variáveis com estes prefixos. Padrão: PUBLIC_
+	ServeJSON  bool     `json:"serve_json"` // Também serve <prefixo>/env.json
+	GlobalName string   `json:"global"`     // Padrão: __ENV__
+}
+
+// envState guarda as variáveis públicas atuais
+type envState struct {
+	mu     sync.Mutex
+	config EnvConfig
+	values map[string]string
+}
+
+// Variáveis de ambiente injetadas, configuradas em main
+var publicEnv = &envState{}
+
+// configure aplica a configuração e carrega as variáveis. Um prefixo vazio (ou "*") é recusado,
+// pois exporia todo o ambiente do processo às páginas.
+func (e *envState) configure(cfg EnvConfig) error {
+	if len(cfg.Prefixes) == 0 {
+		cfg.Prefixes = []string{"PUBLIC_"}
+	}
+	for i, prefix := range cfg.Prefixes {
+		cfg.Prefixes[i] = strings.TrimSuffix(prefix, "*")
+		if cfg.Prefixes[i] == "" {
+			return fmt.Errorf("prefixo de variáveis vazio em %q: liste apenas prefixos específicos, como PUBLIC_", prefix)
+		}
+	}
+	if cfg.GlobalName == "" {
+		cfg.GlobalName = "__ENV__"
+	}
+	e.mu.Lock()
+	e.config = cfg
+	e.mu.Unlock()
+	e.load()
+	return nil
+}
+
+func (e *envState) enabled() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return len(e.config.Files) > 0
+}
+
+func (e *envState) allowed(name string) bool {
+	for _, prefix := range e.config.Prefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// load relê os arquivos .env. Variáveis do ambiente do processo têm prioridade, como no dotenv.
+func (e *envState) load() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	values := make(map[string]string)
+	for _, file := range e.config.Files {
+		vars, err := parseEnvFile(file)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				log.Printf("Aviso: não foi possível ler '%s': %v", file, err)
+			}
+			continue
+		}
+		for name, value := range vars {
+			if e.allowed(name) {
+				values[name] = value
+			}
+		}
+	}
+	for _, kv := range os.Environ() {
+		if name, value, ok := strings.Cut(kv, "="); ok && e.allowed(name) {
+			values[name] = value
+		}
+	}
+	e.values = values
+}
+
+// parseEnvFile lê um arquivo no formato NOME=valor, aceitando 'export', comentários e aspas
+func parseEnvFile(file string) (map[string]string, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	vars := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		name, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		switch {
+		case len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"':
+			value = strings.NewReplacer(`\n`, "\n", `\t`, "\t", `\"`, `"`, `\\`, `\`).Replace(value[1 : len(value)-1])
+		case len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'':
+			value = value[1 : len(value)-1]
+		default:
+			if idx := strings.Index(value, " #"); idx != -1 {
+				value = strings.TrimSpace(value[:idx])
+			}
+		}
+		vars[name] = value
+	}
+	return vars, scanner.Err()
+}
+
+func (e *envState) json() []byte {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	data, _ := json.Marshal(e.values) // json.Marshal escapa <, > e &, seguro dentro de <script>
+	return data
+}
+
+// script é o código que define a variável global
+func (e *envState) script() string {
+	e.mu.Lock()
+	global := e.config.GlobalName
+	e.mu.Unlock()
+	return fmt.Sprintf("window[%q] = %s;", global, e.json())
+}
+
+// tag monta a tag injetada no início do <head>
+func (e *envState) tag(reservedPrefix, nonce string, external bool) string {
+	if external {
+		return fmt.Sprintf("<script src=\"%s/env.js\"></script>\n", publicURL(reservedPrefix))
+	}
+	return fmt.Sprintf("<script%s>%s</script>\n", nonceAttr(nonce), e.script())
+}
+
+// serveEnvJS e serveEnvJSON servem as variáveis como script (modo CSP "external") e como JSON
+func serveEnvJS(w http.ResponseWriter, r *http.Request) {
+	if !publicEnv.enabled() {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/javascript; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	http.ServeContent(w, r, "", time.Time{}, strings.NewReader(publicEnv.script()))
+}
+
+func serveEnvJSON(w http.ResponseWriter, r *http.Request) {
+	publicEnv.mu.Lock()
+	serve := len(publicEnv.config.Files) > 0 && publicEnv.config.ServeJSON
+	publicEnv.mu.Unlock()
+	if !serve {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(publicEnv.json())
+}
+
+// watch recarrega as variáveis e as páginas quando um arquivo .env muda
+func (e *envState) watch() {
+	e.mu.Lock()
+	var files []string
+	for _, file := range e.config.Files {
+		if abs, err := filepath.Abs(file); err == nil {
+			files = append(files, abs)
+		}
+	}
+	e.mu.Unlock()
+	if len(files) == 0 {
+		return
+	}
+	watchExtraFiles(files, func(file string) {
+		e.load()
+		bumpInjectionGeneration()
+		log.Printf("🔄 Variáveis de ambiente recarregadas: %s", file)
+		hub.publish(outboundMessage{data: []byte(`{"type":"reload"}`)})
+	})
+}
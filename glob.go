@@ -0,0 +1,123 @@
+package main
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// expandBraces expande alternativas no formato {a,b} em vários padrões
+func expandBraces(pattern string) []string {
+	open := strings.Index(pattern, "{")
+	if open == -1 {
+		return []string{pattern}
+	}
+	depth := 0
+	for i := open; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				var result []string
+				for _, alt := range splitTopLevel(pattern[open+1 : i]) {
+					result = append(result, expandBraces(pattern[:open]+alt+pattern[i+1:])...)
+				}
+				return result
+			}
+		}
+	}
+	return []string{pattern}
+}
+
+// splitTopLevel divide por vírgulas que não estejam dentro de chaves aninhadas
+func splitTopLevel(s string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// matchGlob verifica se um caminho com separadores '/' corresponde ao padrão.
+// Além da sintaxe de path.Match, suporta '**' (zero ou mais segmentos) e {a,b}.
+func matchGlob(pattern, name string) bool {
+	for _, p := range expandBraces(pattern) {
+		if matchSegments(strings.Split(p, "/"), strings.Split(name, "/")) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pattern[0], name[0]); err != nil || !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// globFiles retorna os arquivos do sistema que correspondem ao padrão (suporta '**').
+func globFiles(pattern string) []string {
+	var files []string
+	for _, p := range expandBraces(filepath.ToSlash(pattern)) {
+		p = path.Clean(p)
+		base := globBaseDir(p)
+		filepath.Walk(filepath.FromSlash(base), func(file string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return nil
+			}
+			if matchGlob(p, filepath.ToSlash(file)) {
+				files = append(files, file)
+			}
+			return nil
+		})
+	}
+	return files
+}
+
+// globBaseDir retorna o diretório fixo que antecede o primeiro curinga do padrão
+func globBaseDir(pattern string) string {
+	segments := strings.Split(pattern, "/")
+	var base []string
+	for _, seg := range segments[:len(segments)-1] {
+		if strings.ContainsAny(seg, "*?[") {
+			break
+		}
+		base = append(base, seg)
+	}
+	if len(base) == 0 {
+		return "."
+	}
+	if len(base) == 1 && base[0] == "" {
+		return "/"
+	}
+	return strings.Join(base, "/")
+}
@@ -0,0 +1,35 @@
+package main
+
+import "testing"
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"/**", "/", true},
+		{"/**", "/a/b/c.js", true},
+		{"/*.js", "/app.js", true},
+		{"/*.js", "/lib/app.js", false},
+		{"/**/*.js", "/app.js", true},
+		{"/**/*.js", "/lib/vendor/app.js", true},
+		{"/**/*.js", "/lib/app.css", false},
+		{"/admin/**", "/admin", true},
+		{"/admin/**", "/admin/users/1", true},
+		{"/admin/**", "/administrator", false},
+		{"/**/*.{html,htm}", "/docs/index.htm", true},
+		{"/**/*.{html,htm}", "/docs/index.xhtml", false},
+		{"/{a,b/{c,d}}/x", "/b/d/x", true},
+		{"/{a,b/{c,d}}/x", "/b/x", false},
+		{"/**/", "/docs/", true},
+		{"/**/", "/docs", false},
+		{"/img/?.png", "/img/a.png", true},
+		{"/img/[", "/img/[", false}, // Padrão inválido não corresponde
+		{"src/**/*.go", "src/main.go", true},
+	}
+	for _, tt := range tests {
+		if got := matchGlob(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("matchGlob(%q, %q) = %v, esperava %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
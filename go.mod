@@ -3,8 +3,10 @@ module brhttp
 go 1.19
 
 require (
+	github.com/andybalholm/brotli v1.1.0
 	github.com/fsnotify/fsnotify v1.9.0
 	github.com/gorilla/websocket v1.5.3
+	github.com/klauspost/compress v1.17.6
 )
 
 require golang.org/x/sys v0.13.0 // indirect
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// HeaderRule altera os cabeçalhos das respostas cujo caminho corresponde a 'paths' (globs) ou 'regex'.
+// A ordem é: remove, preset, set e append. Cache-Control e Access-Control-* não são aceitos: são
+// definidos depois, por cache_rules e cors, e sobrescreveriam a regra.
+type HeaderRule struct {
+	Paths  []string          `json:"paths"` // Padrão: todos os caminhos (se 'regex' também estiver vazio)
+	Regex  string            `json:"regex"`
+	Preset string            `json:"preset"` // cross-origin-isolated ou strict-security
+	Set    map[string]string `json:"set"`
+	Append map[string]string `json:"append"`
+	Remove []string          `json:"remove"`
+
+	regex *regexp.Regexp
+}
+
+// Conjuntos de cabeçalhos prontos
+var headerPresets = map[string]map[string]string{
+	// Habilita SharedArrayBuffer e performance.measureUserAgentSpecificMemory
+	"cross-origin-isolated": {
+		"Cross-Origin-Opener-Policy":   "same-origin",
+		"Cross-Origin-Embedder-Policy": "require-corp",
+		"Cross-Origin-Resource-Policy": "same-origin",
+	},
+	// Cabeçalhos de segurança comuns em produção. O HSTS só vale em HTTPS; a CSP recebe
+	// as liberações do live reload como qualquer outra (veja csp_mode).
+	"strict-security": {
+		"Strict-Transport-Security": "max-age=63072000; includeSubDomains",
+		"Content-Security-Policy":   "default-src 'self'; object-src 'none'; base-uri 'self'; frame-ancestors 'none'",
+		"X-Content-Type-Options":    "nosniff",
+		"X-Frame-Options":           "DENY",
+		"Referrer-Policy":           "strict-origin-when-cross-origin",
+		"Permissions-Policy":        "camera=(), microphone=(), geolocation=(), payment=()",
+	},
+}
+
+// managedHeader informa se o cabeçalho é controlado por outra seção da configuração
+func managedHeader(name string) (string, bool) {
+	name = http.CanonicalHeaderKey(name)
+	switch {
+	case name == "Cache-Control":
+		return "cache_rules", true
+	case strings.HasPrefix(name, "Access-Control-"):
+		return "cors", true
+	}
+	return "", false
+}
+
+// compileHeaderRules valida presets, cabeçalhos e expressões regulares
+func compileHeaderRules(rules []HeaderRule) ([]HeaderRule, error) {
+	for i := range rules {
+		rule := &rules[i]
+		names := append([]string(nil), rule.Remove...)
+		for name := range rule.Set {
+			names = append(names, name)
+		}
+		for name := range rule.Append {
+			names = append(names, name)
+		}
+		for _, name := range names {
+			if section, ok := managedHeader(name); ok {
+				return nil, fmt.Errorf("regra de cabeçalhos %d: %s é definido pela seção %q da configuração", i, name, section)
+			}
+		}
+		if rule.Preset != "" {
+			if _, ok := headerPresets[rule.Preset]; !ok {
+				return nil, fmt.Errorf("regra de cabeçalhos %d: preset inválido %q (use cross-origin-isolated ou strict-security)", i, rule.Preset)
+			}
+		}
+		if rule.Regex != "" {
+			re, err := regexp.Compile(rule.Regex)
+			if err != nil {
+				return nil, fmt.Errorf("regra de cabeçalhos %d: regex inválida: %v", i, err)
+			}
+			rule.regex = re
+		} else if len(rule.Paths) == 0 {
+			rule.Paths = []string{"/**"}
+		}
+	}
+	return rules, nil
+}
+
+func (rule HeaderRule) matches(urlPath string) bool {
+	if rule.regex != nil && rule.regex.MatchString(urlPath) {
+		return true
+	}
+	for _, pattern := range rule.Paths {
+		if matchGlob(pattern, urlPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// applyHeaderRules aplica, em ordem, as regras que correspondem ao caminho
+func applyHeaderRules(rules []HeaderRule, urlPath string, header http.Header) {
+	for _, rule := range rules {
+		if !rule.matches(urlPath) {
+			continue
+		}
+		for _, name := range rule.Remove {
+			header.Del(name)
+		}
+		for name, value := range headerPresets[rule.Preset] {
+			header.Set(name, value)
+		}
+		for name, value := range rule.Set {
+			header.Set(name, value)
+		}
+		for name, value := range rule.Append {
+			header.Add(name, value)
+		}
+	}
+}
+
+// headerRulesMiddleware aplica as regras logo antes do envio dos cabeçalhos, valendo também para
+// o fallback de SPA e a página 404. Fica dentro do liveReloadInjector para que uma CSP definida
+// aqui receba as liberações das tags injetadas; por isso os ganchos de CORS e cache, mais externos,
+// rodam depois (e compileHeaderRules recusa os cabeçalhos que eles definem).
+func headerRulesMiddleware(rules []HeaderRule, next http.Handler) http.Handler {
+	if len(rules) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		urlPath := r.URL.Path
+		hw := &hookWriter{ResponseWriter: w, before: func(status int) {
+			applyHeaderRules(rules, urlPath, w.Header())
+		}}
+		defer hw.finish()
+		next.ServeHTTP(hw, r)
+	})
+}
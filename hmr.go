@@ -0,0 +1,295 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// import x from './a.js', import './a.js', export * from './a.js', export { a } from './a.js'
+	staticImportPattern = regexp.MustCompile(`(\b(?:import|export)\s*(?:[\w*${}\s,]*?\s*from\s*)?)(["'])([^"'\n]+)(["'])`)
+	// import('./a.js')
+	dynamicImportPattern = regexp.MustCompile(`(\bimport\s*\(\s*)(["'])([^"'\n]+)(["'])(\s*\))`)
+	// Sintaxe de módulo no início de uma linha
+	moduleSyntaxPattern  = regexp.MustCompile(`(?m)^\s*(?:import\s*[\w*{'"]|export\s+)`)
+	hotSelfAcceptPattern = regexp.MustCompile(`import\.meta\.hot\.accept\(\s*(?:\)|function\b|async\b|\(|[\w$]+\s*=>)`)
+	hotDepAcceptPattern  = regexp.MustCompile(`import\.meta\.hot\.accept\(\s*(\[[^\]]*\]|["'][^"']+["'])`)
+	quotedStringPattern  = regexp.MustCompile(`["']([^"']+)["']`)
+)
+
+// moduleInfo guarda o que sabemos sobre um módulo ES servido
+type moduleInfo struct {
+	imports      []string
+	importers    map[string]bool
+	hasSyntax    bool
+	selfAccepts  bool
+	acceptedDeps map[string]bool
+	version      int
+}
+
+// moduleGraph é o grafo de importações dos módulos ES em serve_dir
+type moduleGraph struct {
+	mu       sync.Mutex
+	serveDir string
+	modules  map[string]*moduleInfo
+}
+
+// Grafo global de módulos, configurado em main
+var modules = newModuleGraph("")
+
+func newModuleGraph(serveDir string) *moduleGraph {
+	return &moduleGraph{serveDir: serveDir, modules: make(map[string]*moduleInfo)}
+}
+
+func isModuleFile(name string) bool {
+	ext := strings.ToLower(path.Ext(name))
+	return ext == ".js" || ext == ".mjs"
+}
+
+// isLocalSpecifier verifica se o especificador aponta para um arquivo servido (e não um pacote npm)
+func isLocalSpecifier(spec string) bool {
+	return strings.HasPrefix(spec, "./") || strings.HasPrefix(spec, "../") || (strings.HasPrefix(spec, "/") && !strings.HasPrefix(spec, "//"))
+}
+
+// scan percorre serve_dir e monta o grafo inicial
+func (g *moduleGraph) scan() {
+	filepath.Walk(g.serveDir, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() {
+			if file != g.serveDir && (info.Name() == "node_modules" || strings.HasPrefix(info.Name(), ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if isModuleFile(file) {
+			if rel, err := filepath.Rel(g.serveDir, file); err == nil {
+				g.update("/" + filepath.ToSlash(rel))
+			}
+		}
+		return nil
+	})
+}
+
+func (g *moduleGraph) entry(urlPath string) *moduleInfo {
+	m, ok := g.modules[urlPath]
+	if !ok {
+		m = &moduleInfo{importers: make(map[string]bool), acceptedDeps: make(map[string]bool)}
+		g.modules[urlPath] = m
+	}
+	return m
+}
+
+// update relê um módulo e atualiza suas arestas no grafo
+func (g *moduleGraph) update(urlPath string) {
+	source, err := os.ReadFile(filepath.Join(g.serveDir, filepath.FromSlash(urlPath)))
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	m := g.entry(urlPath)
+	for _, imported := range m.imports {
+		if dep, ok := g.modules[imported]; ok {
+			delete(dep.importers, urlPath)
+		}
+	}
+	m.imports = nil
+	m.acceptedDeps = make(map[string]bool)
+	m.hasSyntax, m.selfAccepts = false, false
+	if err != nil {
+		return
+	}
+
+	m.hasSyntax = moduleSyntaxPattern.Match(source)
+	seen := make(map[string]bool)
+	for _, pattern := range []*regexp.Regexp{staticImportPattern, dynamicImportPattern} {
+		for _, match := range pattern.FindAllSubmatch(source, -1) {
+			spec := string(match[3])
+			if !isLocalSpecifier(spec) {
+				continue
+			}
+			imported := resolveLocalReference(urlPath, strings.SplitN(spec, "?", 2)[0])
+			if seen[imported] {
+				continue
+			}
+			seen[imported] = true
+			m.imports = append(m.imports, imported)
+			g.entry(imported).importers[urlPath] = true
+		}
+	}
+
+	m.selfAccepts = hotSelfAcceptPattern.Match(source)
+	for _, match := range hotDepAcceptPattern.FindAllSubmatch(source, -1) {
+		for _, dep := range quotedStringPattern.FindAllSubmatch(match[1], -1) {
+			m.acceptedDeps[resolveLocalReference(urlPath, string(dep[1]))] = true
+		}
+	}
+}
+
+// isModule verifica se um arquivo .js deve ser tratado como módulo ES
+func (g *moduleGraph) isModule(urlPath string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	m, ok := g.modules[urlPath]
+	return ok && (m.hasSyntax || len(m.importers) > 0)
+}
+
+// hmrUpdate descreve um módulo a ser reimportado no navegador
+type hmrUpdate struct {
+	Path     string `json:"path"`     // Módulo a ser reimportado
+	Boundary string `json:"boundary"` // Módulo cujo import.meta.hot.accept trata a atualização
+	Version  int    `json:"version"`
+}
+
+// hmrMessage é enviada aos clientes quando um módulo ES é alterado
+type hmrMessage struct {
+	Type    string      `json:"type"` // "hmr-update" ou "reload"
+	Path    string      `json:"path"`
+	Updates []hmrUpdate `json:"updates,omitempty"`
+}
+
+// propagate calcula as fronteiras de aceitação para a alteração de um módulo.
+// Se algum caminho de importadores chega a uma raiz sem fronteira, exige recarga completa.
+func (g *moduleGraph) propagate(changed string) hmrMessage {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	full := hmrMessage{Type: "reload", Path: changed}
+	invalidated := make(map[string]bool)
+	var boundaries []hmrUpdate
+	seen := make(map[hmrUpdate]bool)
+	addBoundary := func(update hmrUpdate, chain []string) {
+		for _, p := range chain {
+			invalidated[p] = true
+		}
+		if !seen[update] {
+			seen[update] = true
+			boundaries = append(boundaries, update)
+		}
+	}
+
+	var walk func(current string, chain []string) bool
+	walk = func(current string, chain []string) bool {
+		m, ok := g.modules[current]
+		if !ok {
+			return false
+		}
+		chain = append(chain, current)
+		if m.selfAccepts {
+			addBoundary(hmrUpdate{Path: current, Boundary: current}, chain)
+			return true
+		}
+		if len(m.importers) == 0 {
+			return false
+		}
+		for importer := range m.importers {
+			for _, p := range chain {
+				if p == importer {
+					return false // Ciclo sem fronteira
+				}
+			}
+			imp, ok := g.modules[importer]
+			if !ok {
+				return false
+			}
+			if imp.acceptedDeps[current] {
+				addBoundary(hmrUpdate{Path: current, Boundary: importer}, chain)
+				continue
+			}
+			if !walk(importer, chain) {
+				return false
+			}
+		}
+		return true
+	}
+
+	if !walk(changed, nil) {
+		return full
+	}
+	for p := range invalidated {
+		g.modules[p].version++
+	}
+	for i := range boundaries {
+		boundaries[i].Version = g.modules[boundaries[i].Path].version
+	}
+	sort.Slice(boundaries, func(a, b int) bool { return boundaries[a].Path < boundaries[b].Path })
+	return hmrMessage{Type: "hmr-update", Path: changed, Updates: boundaries}
+}
+
+// handleChange atualiza o grafo após a alteração de um arquivo .js/.mjs.
+// Retorna false se o arquivo não for um módulo ES (scripts clássicos usam js-update).
+func (g *moduleGraph) handleChange(urlPath string) (hmrMessage, bool) {
+	g.update(urlPath)
+	if !g.isModule(urlPath) {
+		return hmrMessage{}, false
+	}
+	return g.propagate(urlPath), true
+}
+
+// rewrite adiciona versões aos imports locais e expõe import.meta.hot ao módulo
+func (g *moduleGraph) rewrite(urlPath string, source []byte) []byte {
+	g.mu.Lock()
+	versionOf := func(spec string) int {
+		if m, ok := g.modules[resolveLocalReference(urlPath, spec)]; ok {
+			return m.version
+		}
+		return 0
+	}
+	replace := func(match []byte, pattern *regexp.Regexp) []byte {
+		groups := pattern.FindSubmatch(match)
+		spec := string(groups[3])
+		if !isLocalSpecifier(spec) || strings.Contains(spec, "?") {
+			return match
+		}
+		version := versionOf(spec)
+		if version == 0 {
+			return match
+		}
+		head := len(groups[1]) + len(groups[2]) + len(groups[3])
+		return []byte(fmt.Sprintf("%s%s%s?v=%d%s", groups[1], groups[2], spec, version, match[head:]))
+	}
+	source = staticImportPattern.ReplaceAllFunc(source, func(m []byte) []byte { return replace(m, staticImportPattern) })
+	source = dynamicImportPattern.ReplaceAllFunc(source, func(m []byte) []byte { return replace(m, dynamicImportPattern) })
+	g.mu.Unlock()
+
+	if bytes.Contains(source, []byte("import.meta.hot")) {
+		prelude := []byte("import.meta.hot = window.__brhttpHMR ? window.__brhttpHMR.createHotContext(import.meta.url) : undefined;\n")
+		source = append(prelude, source...)
+	}
+	return source
+}
+
+// hmrModuleMiddleware serve módulos ES reescritos para o protocolo de HMR
+func hmrModuleMiddleware(serveDir string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		urlPath := path.Clean("/" + r.URL.Path)
+		if (r.Method != http.MethodGet && r.Method != http.MethodHead) || !isModuleFile(urlPath) || !modules.isModule(urlPath) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		file := filepath.Join(serveDir, filepath.FromSlash(urlPath))
+		info, err := os.Stat(file)
+		if err != nil || info.IsDir() {
+			next.ServeHTTP(w, r)
+			return
+		}
+		source, err := os.ReadFile(file)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+		// Sem modtime: o conteúdo reescrito depende das versões dos módulos importados
+		w.Header().Set("Cache-Control", "no-cache")
+		http.ServeContent(w, r, urlPath, time.Time{}, bytes.NewReader(modules.rewrite(urlPath, source)))
+	})
+}
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	writeWait      = 10 * time.Second    // Tempo máximo para escrever uma mensagem
+	pongWait       = 60 * time.Second    // Tempo máximo sem receber pong antes de considerar a conexão morta
+	pingPeriod     = (pongWait * 9) / 10 // Intervalo entre pings; menor que pongWait
+	maxMessageSize = 64 * 1024           // Tamanho máximo de uma mensagem recebida do navegador
+
+	defaultSendBuffer = 256
+)
+
+// Políticas para quando a fila de envio de um cliente lento está cheia
+const (
+	dropOldest = "drop-oldest" // Descarta a mensagem mais antiga da fila (padrão)
+	dropNewest = "drop-newest" // Descarta a mensagem nova
+	disconnect = "disconnect"  // Desconecta o cliente; ele reconecta e recarrega a página
+)
+
+// WebSocketConfig configura o hub de clientes de live reload
+type WebSocketConfig struct {
+	SendBuffer int    `json:"send_buffer"` // Mensagens enfileiradas por cliente. Padrão: 256
+	DropPolicy string `json:"drop_policy"` // drop-oldest, drop-newest ou disconnect
+}
+
+// Client é um navegador conectado ao live reload
+type Client struct {
+	conn      *websocket.Conn // nil nos transportes SSE e long polling
+	transport string          // websocket, sse ou polling
+	send      chan []byte
+	host      string
+
+	// Fechado quando o cliente sai do hub; nunca fechamos 'send', evitando envio em canal fechado
+	done      chan struct{}
+	closeOnce sync.Once
+	dropped   int64 // Mensagens descartadas por fila cheia (atômico)
+	lastSeen  int64 // Último poll (unix nano, atômico); apenas no long polling
+
+	// Metadados do cliente, expostos em /api/clients
+	id          string
+	userAgent   string
+	remoteAddr  string
+	connectedAt time.Time
+
+	// Página aberta no navegador e recursos que ela carregou (para recarga direcionada)
+	mu       sync.Mutex
+	url      string
+	viewport clientViewport
+	page     string
+	document string
+	deps     map[string]bool
+	reported bool
+	topics   map[string]bool
+}
+
+// outboundMessage é uma mensagem para os clientes; se 'target' for definido,
+// apenas os clientes para os quais ele retorna true a recebem (ex: inscritos em um tópico).
+type outboundMessage struct {
+	data   []byte
+	target func(*Client) bool
+}
+
+// Hub mantém os clientes conectados e distribui as mensagens sem bloquear quem publica
+type Hub struct {
+	register   chan *Client
+	unregister chan *Client
+	broadcast  chan outboundMessage
+
+	mu      sync.RWMutex
+	clients map[*Client]bool
+
+	sendBuffer int
+	dropPolicy string
+
+	// Métricas expostas em /api/status
+	delivered          int64
+	dropped            int64
+	droppedBroadcasts  int64
+	slowDisconnections int64
+}
+
+// Hub global, configurado em main
+var hub = newHub()
+
+func newHub() *Hub {
+	return &Hub{
+		register:   make(chan *Client),
+		unregister: make(chan *Client),
+		broadcast:  make(chan outboundMessage, 1024),
+		clients:    make(map[*Client]bool),
+		sendBuffer: defaultSendBuffer,
+		dropPolicy: dropOldest,
+	}
+}
+
+func (h *Hub) configure(cfg WebSocketConfig) error {
+	switch cfg.DropPolicy {
+	case "":
+		cfg.DropPolicy = dropOldest
+	case dropOldest, dropNewest, disconnect:
+	default:
+		return fmt.Errorf("drop_policy inválida %q (use %s, %s ou %s)", cfg.DropPolicy, dropOldest, dropNewest, disconnect)
+	}
+	if cfg.SendBuffer <= 0 {
+		cfg.SendBuffer = defaultSendBuffer
+	}
+	h.sendBuffer = cfg.SendBuffer
+	h.dropPolicy = cfg.DropPolicy
+	return nil
+}
+
+// run processa entradas, saídas e mensagens; deve rodar em uma única goroutine
+func (h *Hub) run() {
+	for {
+		select {
+		case client := <-h.register:
+			h.mu.Lock()
+			h.clients[client] = true
+			h.mu.Unlock()
+		case client := <-h.unregister:
+			h.remove(client)
+		case message := <-h.broadcast:
+			for _, client := range h.snapshot() {
+				if message.target != nil && !message.target(client) {
+					continue
+				}
+				h.deliver(client, message.data)
+			}
+		}
+	}
+}
+
+func (h *Hub) remove(client *Client) {
+	h.mu.Lock()
+	delete(h.clients, client)
+	h.mu.Unlock()
+	client.closeOnce.Do(func() { close(client.done) })
+}
+
+// publish enfileira uma mensagem para distribuição sem bloquear quem chama
+func (h *Hub) publish(message outboundMessage) {
+	select {
+	case h.broadcast <- message:
+	default:
+		atomic.AddInt64(&h.droppedBroadcasts, 1)
+		log.Printf("Fila de broadcast cheia; mensagem descartada")
+	}
+}
+
+// deliver coloca a mensagem na fila do cliente aplicando a política de descarte
+func (h *Hub) deliver(client *Client, message []byte) {
+	select {
+	case <-client.done:
+		return
+	case client.send <- message:
+		atomic.AddInt64(&h.delivered, 1)
+		return
+	default:
+	}
+
+	atomic.AddInt64(&h.dropped, 1)
+	atomic.AddInt64(&client.dropped, 1)
+	switch h.dropPolicy {
+	case dropNewest:
+	case disconnect:
+		atomic.AddInt64(&h.slowDisconnections, 1)
+		log.Printf("Cliente %s lento demais; desconectando", client.id)
+		h.remove(client)
+	default:
+		select {
+		case <-client.send:
+		default:
+		}
+		select {
+		case client.send <- message:
+			atomic.AddInt64(&h.delivered, 1)
+		default:
+		}
+	}
+}
+
+// snapshot retorna os clientes conectados no momento
+func (h *Hub) snapshot() []*Client {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	list := make([]*Client, 0, len(h.clients))
+	for client := range h.clients {
+		list = append(list, client)
+	}
+	return list
+}
+
+func (h *Hub) count() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.clients)
+}
+
+// hubStats são as métricas do hub expostas em /api/status
+type hubStats struct {
+	Clients            int            `json:"clients"`
+	Delivered          int64          `json:"delivered"`
+	Dropped            int64          `json:"dropped"`
+	DroppedBroadcasts  int64          `json:"dropped_broadcasts"`
+	SlowDisconnections int64          `json:"slow_disconnections"`
+	DropPolicy         string         `json:"drop_policy"`
+	Topics             map[string]int `json:"topics"`
+}
+
+func (h *Hub) stats() hubStats {
+	stats := hubStats{
+		Delivered:          atomic.LoadInt64(&h.delivered),
+		Dropped:            atomic.LoadInt64(&h.dropped),
+		DroppedBroadcasts:  atomic.LoadInt64(&h.droppedBroadcasts),
+		SlowDisconnections: atomic.LoadInt64(&h.slowDisconnections),
+		DropPolicy:         h.dropPolicy,
+		Topics:             make(map[string]int),
+	}
+	clients := h.snapshot()
+	stats.Clients = len(clients)
+	for _, client := range clients {
+		for _, topic := range client.subscriptions() {
+			stats.Topics[topic]++
+		}
+	}
+	return stats
+}
+
+// newClient cria um cliente ainda não registrado no hub
+func newClient(r *http.Request, transport string) *Client {
+	return &Client{
+		transport:   transport,
+		send:        make(chan []byte, hub.sendBuffer),
+		done:        make(chan struct{}),
+		host:        r.Host,
+		id:          newClientID(),
+		userAgent:   r.UserAgent(),
+		remoteAddr:  r.RemoteAddr,
+		connectedAt: time.Now(),
+	}
+}
+
+// find procura um cliente conectado pelo identificador
+func (h *Hub) find(id string) *Client {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for client := range h.clients {
+		if client.id == id {
+			return client
+		}
+	}
+	return nil
+}
+
+// handleConnections lida com novas conexões WebSocket
+func handleConnections(w http.ResponseWriter, r *http.Request) {
+	ws, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("Erro ao fazer upgrade para WebSocket: %v", err)
+		return
+	}
+	defer ws.Close()
+
+	client := newClient(r, "websocket")
+	client.conn = ws
+	hub.register <- client
+	defer func() { hub.unregister <- client }()
+
+	go client.writePump()
+
+	ws.SetReadLimit(maxMessageSize)
+	ws.SetReadDeadline(time.Now().Add(pongWait))
+	ws.SetPongHandler(func(string) error {
+		return ws.SetReadDeadline(time.Now().Add(pongWait))
+	})
+	for {
+		_, data, err := ws.ReadMessage()
+		if err != nil {
+			return
+		}
+		client.handleClientMessage(data, r.Host)
+	}
+}
+
+// writePump envia as mensagens da fila do cliente e os pings de keepalive
+func (c *Client) writePump() {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+	for {
+		select {
+		case message := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.TextMessage, message); err != nil {
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		case <-c.done:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+			return
+		}
+	}
+}
+
+// queue envia uma mensagem apenas para este cliente
+func (c *Client) queue(message []byte) {
+	hub.deliver(c, message)
+}
+
+// subscribe e unsubscribe alteram os tópicos que o cliente recebe
+func (c *Client) subscribe(topics []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.topics == nil {
+		c.topics = make(map[string]bool)
+	}
+	for _, topic := range topics {
+		c.topics[topic] = true
+	}
+}
+
+func (c *Client) unsubscribe(topics []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, topic := range topics {
+		delete(c.topics, topic)
+	}
+}
+
+func (c *Client) subscribed(topic string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.topics[topic]
+}
+
+func (c *Client) subscriptions() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	topics := make([]string, 0, len(c.topics))
+	for topic := range c.topics {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	return topics
+}
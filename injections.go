@@ -0,0 +1,312 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Posições de injeção no documento
+const (
+	positionHeadStart = "head-start" // Logo após <head>
+	positionHeadEnd   = "head-end"   // Antes de </head> (padrão)
+	positionBodyEnd   = "body-end"   // Antes de </body>, junto do cliente de live reload
+)
+
+// InjectionRule injeta um trecho nas páginas cujo caminho corresponde a 'paths'.
+// O conteúdo vem de 'file' (relido e com recarga das páginas quando alterado) ou de 'content'.
+// Variáveis de template: {{path}} (caminho da página) e {{port}} (porta do servidor).
+type InjectionRule struct {
+	Paths    []string `json:"paths"`    // Globs (ex: "/admin/**"). Padrão: todas as páginas
+	Position string   `json:"position"` // head-start, head-end ou body-end
+	Type     string   `json:"type"`     // js, css, html ou url
+	File     string   `json:"file"`
+	Content  string   `json:"content"`
+	URL      string   `json:"url"` // Para type "url": carregado como <script> (ou <link> se terminar em .css)
+}
+
+// injectionSet guarda as regras de injeção e o conteúdo atual dos arquivos
+type injectionSet struct {
+	mu       sync.Mutex
+	rules    []InjectionRule
+	contents map[int]string
+	port     string
+}
+
+// Regras de injeção globais, configuradas em main
+var injections = &injectionSet{contents: make(map[int]string)}
+
+// legacyInjectionRules converte inject_js_path e inject_css_path em regras
+func legacyInjectionRules(jsPath, cssPath string) []InjectionRule {
+	var rules []InjectionRule
+	if cssPath != "" {
+		rules = append(rules, InjectionRule{Type: "css", File: cssPath})
+	}
+	if jsPath != "" {
+		rules = append(rules, InjectionRule{Type: "js", File: jsPath})
+	}
+	return rules
+}
+
+// configure valida as regras e lê os arquivos
+func (s *injectionSet) configure(rules []InjectionRule, port int) error {
+	for i := range rules {
+		rule := &rules[i]
+		if rule.Position == "" {
+			rule.Position = positionHeadEnd
+		}
+		if len(rule.Paths) == 0 {
+			rule.Paths = []string{"/**"}
+		}
+		switch rule.Position {
+		case positionHeadStart, positionHeadEnd, positionBodyEnd:
+		default:
+			return fmt.Errorf("injeção %d: posição inválida %q", i, rule.Position)
+		}
+		switch rule.Type {
+		case "js", "css", "html":
+			if rule.File == "" && rule.Content == "" {
+				return fmt.Errorf("injeção %d: informe 'file' ou 'content'", i)
+			}
+		case "url":
+			if rule.URL == "" {
+				return fmt.Errorf("injeção %d: informe 'url'", i)
+			}
+		default:
+			return fmt.Errorf("injeção %d: tipo inválido %q (use js, css, html ou url)", i, rule.Type)
+		}
+	}
+
+	s.mu.Lock()
+	s.rules = rules
+	s.port = strconv.Itoa(port)
+	s.mu.Unlock()
+	for i, rule := range rules {
+		if rule.File != "" {
+			s.load(i)
+		}
+	}
+	return nil
+}
+
+// load (re)lê o arquivo de uma regra
+func (s *injectionSet) load(i int) {
+	s.mu.Lock()
+	file := s.rules[i].File
+	s.mu.Unlock()
+	content, err := os.ReadFile(file)
+	if err != nil {
+		log.Printf("Aviso: não foi possível ler o arquivo para injeção '%s': %v", file, err)
+	}
+	s.mu.Lock()
+	s.contents[i] = string(content)
+	s.mu.Unlock()
+}
+
+// matches verifica se a regra se aplica a uma página
+func (rule InjectionRule) matches(pagePath string) bool {
+	for _, pattern := range rule.Paths {
+		if matchGlob(pattern, pagePath) {
+			return true
+		}
+	}
+	return false
+}
+
+// renderedInjection é uma regra pronta para uma página
+type renderedInjection struct {
+	index    int
+	rule     InjectionRule
+	content  string
+	position string
+}
+
+// forPage retorna as injeções que se aplicam à página, com as variáveis substituídas
+func (s *injectionSet) forPage(pagePath string) []renderedInjection {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var result []renderedInjection
+	for i, rule := range s.rules {
+		if !rule.matches(pagePath) {
+			continue
+		}
+		content := rule.Content
+		if rule.File != "" {
+			content = s.contents[i]
+		}
+		// Os valores são escapados conforme o contexto em que o trecho é inserido
+		escape := html.EscapeString
+		if rule.Type == "js" {
+			escape = template.JSEscapeString
+		}
+		content = strings.NewReplacer("{{path}}", escape(pagePath), "{{port}}", s.port).Replace(content)
+		result = append(result, renderedInjection{index: i, rule: rule, content: content, position: rule.Position})
+	}
+	return result
+}
+
+// hasInlineStyle informa se alguma injeção da página insere um <style>
+func hasInlineStyle(list []renderedInjection) bool {
+	for _, item := range list {
+		if item.rule.Type == "css" {
+			return true
+		}
+	}
+	return false
+}
+
+// tag monta o HTML de uma injeção. No modo CSP "external", JS e CSS são servidos
+// como arquivos em <prefixo>/injections/<n>.
+func (item renderedInjection) tag(reservedPrefix, pagePath, nonce string, external bool) string {
+	src := fmt.Sprintf("%s/injections/%d?path=%s", publicURL(reservedPrefix), item.index, url.QueryEscape(pagePath))
+	switch item.rule.Type {
+	case "js":
+		if external {
+			return fmt.Sprintf("<script src=\"%s\"></script>\n", html.EscapeString(src))
+		}
+		return fmt.Sprintf("<script%s>\n%s\n</script>\n", nonceAttr(nonce), item.content)
+	case "css":
+		if external {
+			return fmt.Sprintf("<link rel=\"stylesheet\" href=\"%s\">\n", html.EscapeString(src))
+		}
+		return fmt.Sprintf("<style%s>\n%s\n</style>\n", nonceAttr(nonce), item.content)
+	case "url":
+		if strings.HasSuffix(strings.ToLower(path.Ext(strings.SplitN(item.rule.URL, "?", 2)[0])), ".css") {
+			return fmt.Sprintf("<link rel=\"stylesheet\" href=\"%s\"%s>\n", html.EscapeString(item.rule.URL), nonceAttr(nonce))
+		}
+		return fmt.Sprintf("<script src=\"%s\"%s></script>\n", html.EscapeString(item.rule.URL), nonceAttr(nonce))
+	default:
+		return item.content + "\n"
+	}
+}
+
+// serveInjection serve o conteúdo de uma injeção JS/CSS (<prefixo>/injections/<n>?path=/pagina)
+func serveInjection(w http.ResponseWriter, r *http.Request) {
+	index, err := strconv.Atoi(path.Base(r.URL.Path))
+	pagePath := r.URL.Query().Get("path")
+	if pagePath == "" {
+		pagePath = "/"
+	}
+	if err == nil {
+		for _, item := range injections.forPage(pagePath) {
+			if item.index != index || (item.rule.Type != "js" && item.rule.Type != "css") {
+				continue
+			}
+			contentType := "application/javascript; charset=utf-8"
+			if item.rule.Type == "css" {
+				contentType = "text/css; charset=utf-8"
+			}
+			w.Header().Set("Content-Type", contentType)
+			w.Header().Set("Cache-Control", "no-cache")
+			http.ServeContent(w, r, "", time.Time{}, strings.NewReader(item.content))
+			return
+		}
+	}
+	http.NotFound(w, r)
+}
+
+// watch recarrega os arquivos das injeções quando alterados e recarrega as páginas afetadas
+func (s *injectionSet) watch() {
+	s.mu.Lock()
+	files := make(map[string][]int)
+	for i, rule := range s.rules {
+		if rule.File != "" {
+			if abs, err := filepath.Abs(rule.File); err == nil {
+				files[abs] = append(files[abs], i)
+			}
+		}
+	}
+	s.mu.Unlock()
+	if len(files) == 0 {
+		return
+	}
+
+	var list []string
+	for file := range files {
+		list = append(list, file)
+	}
+	watchExtraFiles(list, func(file string) {
+		var patterns []string
+		for _, i := range files[file] {
+			s.load(i)
+			patterns = append(patterns, s.rules[i].Paths...)
+		}
+		bumpInjectionGeneration()
+		log.Printf("🔄 Arquivo de injeção alterado: %s", file)
+		message := []byte(`{"type":"reload"}`)
+		hub.publish(outboundMessage{data: message, target: func(c *Client) bool {
+			page := c.info().Page
+			if page == "" {
+				return true
+			}
+			for _, pattern := range patterns {
+				if matchGlob(pattern, page) {
+					return true
+				}
+			}
+			return false
+		}})
+	})
+}
+
+// watchExtraFiles observa arquivos fora de serve_dir (observando seus diretórios, para
+// acompanhar editores que salvam renomeando) e chama onChange com o caminho absoluto.
+func watchExtraFiles(files []string, onChange func(file string)) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Printf("Aviso: não foi possível observar %v: %v", files, err)
+		return
+	}
+	wanted := make(map[string]bool)
+	dirs := make(map[string]bool)
+	for _, file := range files {
+		wanted[file] = true
+		dirs[filepath.Dir(file)] = true
+	}
+	for dir := range dirs {
+		if err := watcher.Add(dir); err != nil {
+			log.Printf("Aviso: não foi possível observar %s: %v", dir, err)
+		}
+	}
+
+	go func() {
+		defer watcher.Close()
+		timers := make(map[string]*time.Timer)
+		var mu sync.Mutex
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				file := filepath.Clean(event.Name)
+				if !wanted[file] || event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename|fsnotify.Remove) == 0 {
+					continue
+				}
+				mu.Lock()
+				if timer, ok := timers[file]; ok {
+					timer.Stop()
+				}
+				timers[file] = time.AfterFunc(100*time.Millisecond, func() { onChange(file) })
+				mu.Unlock()
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Printf("Erro no watcher: %v", err)
+			}
+		}
+	}()
+}
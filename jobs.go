@@ -0,0 +1,531 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultJobHistoryLimit     = 100
+	defaultJobOutputLimitBytes = 64 * 1024
+
+	// Tempo que a saída de um job ainda é lida depois que o processo termina: processos criados
+	// por ele (e que não foram encerrados) podem manter a saída aberta indefinidamente
+	jobOutputDrainTimeout = 2 * time.Second
+
+	// Tempo que DELETE /api/jobs/{id} espera o job terminar antes de responder 202
+	jobCancelWait = 5 * time.Second
+)
+
+// Estados possíveis de um job
+const (
+	jobStatusRunning   = "running"
+	jobStatusSucceeded = "succeeded"
+	jobStatusFailed    = "failed"
+	jobStatusCanceled  = "canceled"
+)
+
+// outputBuffer é um buffer circular de tamanho fixo para a saída de um job.
+// Mantém apenas os últimos 'size' bytes e permite que leitores acompanhem
+// novas escritas a partir de um offset absoluto.
+type outputBuffer struct {
+	mu      sync.Mutex
+	buf     []byte
+	size    int
+	total   int64
+	changed chan struct{}
+	closed  bool
+}
+
+func newOutputBuffer(size int) *outputBuffer {
+	return &outputBuffer{size: size, changed: make(chan struct{})}
+}
+
+func (b *outputBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return n, nil
+	}
+	if b.buf == nil {
+		b.buf = make([]byte, b.size)
+	}
+	if len(p) > b.size {
+		b.total += int64(len(p) - b.size)
+		p = p[len(p)-b.size:]
+	}
+	for len(p) > 0 {
+		pos := int(b.total % int64(b.size))
+		c := copy(b.buf[pos:], p)
+		p = p[c:]
+		b.total += int64(c)
+	}
+	close(b.changed)
+	b.changed = make(chan struct{})
+	return n, nil
+}
+
+// readFrom retorna os bytes disponíveis a partir de 'offset', o novo offset,
+// um canal que é fechado na próxima escrita e se o buffer já foi encerrado.
+func (b *outputBuffer) readFrom(offset int64) ([]byte, int64, <-chan struct{}, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	oldest := b.total - int64(b.size)
+	if oldest < 0 {
+		oldest = 0
+	}
+	if offset < oldest {
+		offset = oldest
+	}
+	if offset > b.total {
+		offset = b.total
+	}
+	out := make([]byte, int(b.total-offset))
+	for i := 0; i < len(out); {
+		pos := int((offset + int64(i)) % int64(b.size))
+		i += copy(out[i:], b.buf[pos:])
+	}
+	return out, b.total, b.changed, b.closed
+}
+
+func (b *outputBuffer) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.closed {
+		b.closed = true
+		close(b.changed)
+	}
+}
+
+// Job representa uma execução de comando externo (API, webhook, etc.)
+type Job struct {
+	ID        string
+	Source    string
+	Command   string
+	Args      []string
+	StartedAt time.Time
+
+	mu       sync.Mutex
+	endedAt  time.Time
+	exitCode int
+	status   string
+	errMsg   string
+	canceled bool
+
+	output *outputBuffer
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// jobInfo é a representação JSON de um job
+type jobInfo struct {
+	ID         string     `json:"id"`
+	Source     string     `json:"source"`
+	Command    string     `json:"command"`
+	Args       []string   `json:"args"`
+	Status     string     `json:"status"`
+	StartedAt  time.Time  `json:"started_at"`
+	EndedAt    *time.Time `json:"ended_at,omitempty"`
+	DurationMs int64      `json:"duration_ms"`
+	ExitCode   *int       `json:"exit_code,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	Output     *string    `json:"output,omitempty"`
+}
+
+func (j *Job) info(withOutput bool) jobInfo {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	info := jobInfo{
+		ID:        j.ID,
+		Source:    j.Source,
+		Command:   j.Command,
+		Args:      j.Args,
+		Status:    j.status,
+		StartedAt: j.StartedAt,
+		Error:     j.errMsg,
+	}
+	if j.status == jobStatusRunning {
+		info.DurationMs = time.Since(j.StartedAt).Milliseconds()
+	} else {
+		endedAt := j.endedAt
+		exitCode := j.exitCode
+		info.EndedAt = &endedAt
+		info.ExitCode = &exitCode
+		info.DurationMs = endedAt.Sub(j.StartedAt).Milliseconds()
+	}
+	if withOutput {
+		data, _, _, _ := j.output.readFrom(0)
+		output := string(data)
+		info.Output = &output
+	}
+	return info
+}
+
+// Status retorna o estado atual do job
+func (j *Job) Status() string {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.status
+}
+
+// ExitCode retorna o código de saída (válido apenas após o término)
+func (j *Job) ExitCode() int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.exitCode
+}
+
+// Done retorna um canal que é fechado quando o job termina
+func (j *Job) Done() <-chan struct{} {
+	return j.done
+}
+
+// Output retorna a saída acumulada (limitada ao tamanho do buffer)
+func (j *Job) Output() string {
+	data, _, _, _ := j.output.readFrom(0)
+	return string(data)
+}
+
+// Cancel interrompe um job em execução. Retorna false se o job já terminou.
+func (j *Job) Cancel() bool {
+	j.mu.Lock()
+	if j.status != jobStatusRunning {
+		j.mu.Unlock()
+		return false
+	}
+	j.canceled = true
+	j.mu.Unlock()
+	j.cancel()
+	return true
+}
+
+func (j *Job) finish(err error, exitCode int) {
+	j.mu.Lock()
+	j.endedAt = time.Now()
+	j.exitCode = exitCode
+	switch {
+	case j.canceled:
+		j.status = jobStatusCanceled
+	case err != nil:
+		j.status = jobStatusFailed
+		j.errMsg = err.Error()
+	default:
+		j.status = jobStatusSucceeded
+	}
+	j.mu.Unlock()
+	j.output.close()
+	close(j.done)
+}
+
+// jobRegistry mantém o histórico de jobs executados
+type jobRegistry struct {
+	mu               sync.Mutex
+	jobs             map[string]*Job
+	order            []*Job
+	nextID           int
+	historyLimit     int
+	outputLimitBytes int
+}
+
+func newJobRegistry(historyLimit, outputLimitBytes int) *jobRegistry {
+	r := &jobRegistry{jobs: make(map[string]*Job)}
+	r.setLimits(historyLimit, outputLimitBytes)
+	return r
+}
+
+// Registro global de jobs
+var jobs = newJobRegistry(defaultJobHistoryLimit, defaultJobOutputLimitBytes)
+
+func (r *jobRegistry) setLimits(historyLimit, outputLimitBytes int) {
+	if historyLimit <= 0 {
+		historyLimit = defaultJobHistoryLimit
+	}
+	if outputLimitBytes <= 0 {
+		outputLimitBytes = defaultJobOutputLimitBytes
+	}
+	r.mu.Lock()
+	r.historyLimit = historyLimit
+	r.outputLimitBytes = outputLimitBytes
+	r.mu.Unlock()
+}
+
+// start inicia a execução de um comando como um novo job
+func (r *jobRegistry) start(source, command string, args []string) *Job {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	r.mu.Lock()
+	r.nextID++
+	job := &Job{
+		ID:        strconv.Itoa(r.nextID),
+		Source:    source,
+		Command:   command,
+		Args:      args,
+		StartedAt: time.Now(),
+		status:    jobStatusRunning,
+		output:    newOutputBuffer(r.outputLimitBytes),
+		cancel:    cancel,
+		done:      make(chan struct{}),
+	}
+	r.jobs[job.ID] = job
+	r.order = append(r.order, job)
+	r.prune()
+	r.mu.Unlock()
+
+	fail := func(err error) *Job {
+		log.Printf("Erro ao iniciar job %s '%s': %v", job.ID, command, err)
+		fmt.Fprintf(job.output, "%v\n", err)
+		cancel()
+		job.finish(err, -1)
+		return job
+	}
+
+	// O comando roda em um grupo de processos próprio, encerrado por inteiro no cancelamento.
+	// A saída vai para um pipe lido aqui, para que cmd.Wait não dependa dele ser fechado.
+	cmd := exec.Command(command, args...)
+	setProcessGroup(cmd)
+	outputReader, outputWriter, err := os.Pipe()
+	if err != nil {
+		return fail(err)
+	}
+	cmd.Stdout = outputWriter
+	cmd.Stderr = outputWriter
+
+	log.Printf("Job %s (%s) iniciado: %s %v", job.ID, source, command, args)
+	err = cmd.Start()
+	outputWriter.Close()
+	if err != nil {
+		outputReader.Close()
+		return fail(err)
+	}
+
+	copied := make(chan struct{})
+	go func() {
+		io.Copy(io.MultiWriter(job.output, os.Stdout), outputReader)
+		close(copied)
+	}()
+
+	exited := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			select {
+			case <-exited:
+			default:
+				if err := killProcessGroup(cmd.Process); err != nil {
+					log.Printf("Erro ao encerrar job %s: %v", job.ID, err)
+				}
+			}
+		case <-exited:
+		}
+	}()
+
+	go func() {
+		defer cancel()
+		err := cmd.Wait()
+		close(exited)
+		select {
+		case <-copied:
+		case <-time.After(jobOutputDrainTimeout):
+			log.Printf("Job %s: a saída continua aberta por processos filhos; deixando de acompanhá-la", job.ID)
+		}
+		outputReader.Close()
+		exitCode := 0
+		if cmd.ProcessState != nil {
+			exitCode = cmd.ProcessState.ExitCode()
+		}
+		job.finish(err, exitCode)
+		if err != nil {
+			log.Printf("Job %s '%s' terminou com erro (%s): %v", job.ID, command, job.Status(), err)
+		} else {
+			log.Printf("Job %s '%s' concluído com sucesso", job.ID, command)
+		}
+	}()
+
+	return job
+}
+
+// prune remove os jobs finalizados mais antigos além do limite do histórico.
+// Deve ser chamado com o mutex travado.
+func (r *jobRegistry) prune() {
+	excess := len(r.order) - r.historyLimit
+	if excess <= 0 {
+		return
+	}
+	kept := r.order[:0]
+	for _, job := range r.order {
+		if excess > 0 && job.Status() != jobStatusRunning {
+			delete(r.jobs, job.ID)
+			excess--
+			continue
+		}
+		kept = append(kept, job)
+	}
+	r.order = kept
+}
+
+// cancelAll interrompe os jobs em execução e aguarda, por um tempo limitado, o seu término
+func (r *jobRegistry) cancelAll() {
+	var canceled []*Job
+	for _, job := range r.list() {
+		if job.Cancel() {
+			canceled = append(canceled, job)
+		}
+	}
+	deadline := time.After(jobCancelWait)
+	for _, job := range canceled {
+		select {
+		case <-job.Done():
+		case <-deadline:
+			return
+		}
+	}
+}
+
+func (r *jobRegistry) get(id string) *Job {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.jobs[id]
+}
+
+func (r *jobRegistry) list() []*Job {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	list := make([]*Job, len(r.order))
+	copy(list, r.order)
+	sort.SliceStable(list, func(a, b int) bool {
+		return list[a].StartedAt.After(list[b].StartedAt)
+	})
+	return list
+}
+
+// writeJSON serializa 'v' como resposta JSON com o status informado
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// handleJobsAPI lista os jobs (GET /api/jobs)
+func handleJobsAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+		return
+	}
+	list := jobs.list()
+	infos := make([]jobInfo, 0, len(list))
+	for _, job := range list {
+		infos = append(infos, job.info(false))
+	}
+	writeJSON(w, http.StatusOK, infos)
+}
+
+// handleJobAPI lida com /api/jobs/{id} e /api/jobs/{id}/log
+func handleJobAPI(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/jobs/"), "/"), "/")
+	job := jobs.get(parts[0])
+	if job == nil {
+		http.Error(w, "Job não encontrado", http.StatusNotFound)
+		return
+	}
+
+	if len(parts) == 2 && parts[1] == "log" {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+			return
+		}
+		if strings.Contains(r.Header.Get("Accept"), "text/event-stream") || r.URL.Query().Get("follow") != "" {
+			streamJobLog(w, r, job)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		io.WriteString(w, job.Output())
+		return
+	}
+	if len(parts) != 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, job.info(true))
+	case http.MethodDelete:
+		if !job.Cancel() {
+			http.Error(w, "Job já finalizado", http.StatusConflict)
+			return
+		}
+		log.Printf("Job %s cancelado via API", job.ID)
+		select {
+		case <-job.Done():
+			writeJSON(w, http.StatusOK, job.info(false))
+		case <-time.After(jobCancelWait):
+			// O cancelamento segue em andamento; o estado final aparece em GET /api/jobs/{id}
+			writeJSON(w, http.StatusAccepted, job.info(false))
+		}
+	default:
+		http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+	}
+}
+
+// streamJobLog transmite a saída de um job via Server-Sent Events até o seu término
+func streamJobLog(w http.ResponseWriter, r *http.Request, job *Job) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming não suportado", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	var offset int64
+	for {
+		data, next, changed, closed := job.output.readFrom(offset)
+		offset = next
+		if len(data) > 0 {
+			writeSSEEvent(w, "output", data)
+			flusher.Flush()
+		}
+		if closed {
+			info, _ := json.Marshal(job.info(false))
+			writeSSEEvent(w, "end", info)
+			flusher.Flush()
+			return
+		}
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// writeSSEEvent escreve um evento SSE, dividindo os dados em várias linhas 'data:'
+func writeSSEEvent(w io.Writer, event string, data []byte) {
+	var buf bytes.Buffer
+	if event != "" {
+		fmt.Fprintf(&buf, "event: %s\n", event)
+	}
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		buf.WriteString("data: ")
+		buf.Write(bytes.TrimSuffix(line, []byte("\r")))
+		buf.WriteByte('\n')
+	}
+	buf.WriteByte('\n')
+	w.Write(buf.Bytes())
+}
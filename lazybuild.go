@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BuildOnRequestRule define um arquivo gerado sob demanda quando for requisitado
+type BuildOnRequestRule struct {
+	Path    string   `json:"path"`    // Glob do caminho requisitado (ex: "/dist/*.css")
+	Sources []string `json:"sources"` // Globs dos arquivos de origem (ex: "src/styles/**/*.scss")
+	Output  string   `json:"output"`  // Opcional: arquivo gerado; padrão é serve_dir + caminho requisitado
+	Command string   `json:"command"`
+	Args    []string `json:"args"` // Aceita {{path}} e {{output}}
+}
+
+// buildCall representa um build em andamento, compartilhado por requisições concorrentes
+type buildCall struct {
+	done chan struct{}
+	job  *Job
+}
+
+var (
+	buildsMutex    sync.Mutex
+	buildsInFlight = make(map[string]*buildCall)
+)
+
+// isStale verifica se o alvo não existe ou se alguma origem é mais recente que ele
+func isStale(output string, sources []string) bool {
+	info, err := os.Stat(output)
+	if err != nil {
+		return true
+	}
+	outputTime := info.ModTime()
+	for _, pattern := range sources {
+		for _, file := range globFiles(pattern) {
+			if srcInfo, err := os.Stat(file); err == nil && srcInfo.ModTime().After(outputTime) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// runBuild executa o comando da regra, unindo requisições concorrentes para o mesmo alvo
+func runBuild(rule BuildOnRequestRule, urlPath, output string) *Job {
+	buildsMutex.Lock()
+	if call, ok := buildsInFlight[output]; ok {
+		buildsMutex.Unlock()
+		<-call.done
+		return call.job
+	}
+	call := &buildCall{done: make(chan struct{})}
+	buildsInFlight[output] = call
+	buildsMutex.Unlock()
+
+	defer func() {
+		buildsMutex.Lock()
+		delete(buildsInFlight, output)
+		buildsMutex.Unlock()
+		close(call.done)
+	}()
+
+	// Outra requisição pode ter concluído o build enquanto esperávamos
+	if !isStale(output, rule.Sources) {
+		return nil
+	}
+
+	args := make([]string, len(rule.Args))
+	for i, arg := range rule.Args {
+		arg = strings.ReplaceAll(arg, "{{path}}", urlPath)
+		args[i] = strings.ReplaceAll(arg, "{{output}}", output)
+	}
+	log.Printf("Build sob demanda para %s: %s %v", urlPath, rule.Command, args)
+	start := time.Now()
+	call.job = jobs.start("build_on_request", rule.Command, args)
+	<-call.job.Done()
+	log.Printf("Build sob demanda para %s terminou (%s) em %s", urlPath, call.job.Status(), time.Since(start))
+	return call.job
+}
+
+// buildOnRequestMiddleware reconstrói arquivos desatualizados antes de servi-los
+func buildOnRequestMiddleware(serveDir string, rules []BuildOnRequestRule, next http.Handler) http.Handler {
+	if len(rules) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+		urlPath := path.Clean("/" + r.URL.Path)
+		for _, rule := range rules {
+			if !matchGlob(rule.Path, urlPath) {
+				continue
+			}
+			output := filepath.Join(serveDir, filepath.FromSlash(urlPath))
+			if rule.Output != "" {
+				output = strings.ReplaceAll(rule.Output, "{{path}}", strings.TrimPrefix(urlPath, "/"))
+			}
+			if isStale(output, rule.Sources) {
+				if job := runBuild(rule, urlPath, output); job != nil && job.Status() != jobStatusSucceeded {
+					writeBuildErrorPage(w, urlPath, job)
+					return
+				}
+			}
+			break
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// writeBuildErrorPage responde com 500 e a saída do comando que falhou
+func writeBuildErrorPage(w http.ResponseWriter, urlPath string, job *Job) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusInternalServerError)
+	fmt.Fprintf(w, `<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Falha no build: %[1]s</title></head>
+<body style="font-family: monospace; background: #1e1e1e; color: #eee; padding: 1em;">
+<h1 style="color: #ff6b6b;">Falha no build de %[1]s</h1>
+<p>Comando: %[2]s %[3]s<br>Job: %[4]s &mdash; código de saída %[5]d</p>
+<pre style="white-space: pre-wrap; background: #111; padding: 1em;">%[6]s</pre>
+</body>
+</html>
+`, html.EscapeString(urlPath), html.EscapeString(job.Command), html.EscapeString(strings.Join(job.Args, " ")),
+		html.EscapeString(job.ID), job.ExitCode(), html.EscapeString(job.Output()))
+}
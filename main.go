@@ -2,19 +2,19 @@ package main
 
 import (
 	"bytes"
-	"compress/gzip"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
+	"mime"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
 	"os"
-	"os/exec" // Import para executar comandos externos
+	"os/signal"
 	"path/filepath"
 	"strings"
-	"sync" // Para proteger o mapa de clientes WebSocket
+	"syscall"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
@@ -25,6 +25,7 @@ import (
 type ProxyRule struct {
 	Path   string `json:"path"`
 	Target string `json:"target"`
+	Inject bool   `json:"inject"` // Injeta o live reload no HTML retornado pelo backend
 }
 
 // RewriteRule define uma regra de reescrita de URL
@@ -42,10 +43,15 @@ type RedirectRule struct {
 
 // CommandWebhookRule define uma regra para executar um comando externo em um evento
 type CommandWebhookRule struct {
-	Event   string   `json:"event"` // "file_change", "server_start", "server_stop"
+	Event   string   `json:"event"` // "file_change", "server_start", "server_stop", "schedule"
 	Path    string   `json:"path"`  // Optional: regex or prefix for file path (for file_change)
 	Command string   `json:"command"`
 	Args    []string `json:"args"`
+
+	// Para o evento "schedule": expressão cron (ex: "0 3 * * *", "@daily") ou intervalo (ex: "10m")
+	Name     string `json:"name"`
+	Schedule string `json:"schedule"`
+	Interval string `json:"interval"`
 }
 
 // Configuração do servidor
@@ -67,92 +73,73 @@ type Config struct {
 	APIToken               string               `json:"api_token"`
 	NotificationWebhookURL string               `json:"notification_webhook_url"`
 	CommandWebhooks        []CommandWebhookRule `json:"command_webhooks"`
-}
+	JobHistoryLimit        int                  `json:"job_history_limit"`
+	JobOutputLimitBytes    int                  `json:"job_output_limit_bytes"`
 
-// Global para o upgrader de WebSocket
-var upgrader = websocket.Upgrader{
-	ReadBufferSize:  1024,
-	WriteBufferSize: 1024,
-	CheckOrigin: func(r *http.Request) bool {
-		return true
-	},
-}
+	// Comandos nomeados permitidos em /api/command
+	Commands map[string]CommandDefinition `json:"commands"`
+	// Permite command+args livres em /api/command (apenas a partir do loopback)
+	UnsafeArbitraryCommands bool `json:"unsafe_arbitrary_commands"`
 
-// Cliente WebSocket
-type Client struct {
-	conn *websocket.Conn
-	send chan []byte
-}
+	// Sincronização de rolagem, cliques, formulários e navegação entre dispositivos
+	Sync SyncConfig `json:"sync"`
+	// Encaminhamento de console.*, erros e rejeições não tratadas do navegador
+	Console ConsoleConfig `json:"console"`
 
-// Pool de clientes WebSocket
-var clients = make(map[*Client]bool)
-var clientsMutex = &sync.Mutex{} // Mutex para proteger o mapa de clientes
-var broadcast = make(chan []byte)
-var serverStartTime = time.Now() // Para o endpoint /api/status
+	// Arquivos reconstruídos sob demanda quando requisitados
+	BuildOnRequest []BuildOnRequestRule `json:"build_on_request"`
 
-// handleConnections lida com novas conexões WebSocket
-func handleConnections(w http.ResponseWriter, r *http.Request) {
-	ws, err := upgrader.Upgrade(w, r, nil)
-	if err != nil {
-		log.Printf("Erro ao fazer upgrade para WebSocket: %v", err)
-		return
-	}
-	defer ws.Close()
+	// Prefixo das rotas internas (cliente de live reload, WebSocket). Padrão: /__brhttp
+	ReservedPrefix string `json:"reserved_prefix"`
 
-	client := &Client{conn: ws, send: make(chan []byte, 256)}
-	clientsMutex.Lock()
-	clients[client] = true
-	clientsMutex.Unlock()
+	// Base pública quando o brhttp fica atrás de um proxy com prefixo de caminho (ex: "/app")
+	PublicBaseURL string `json:"public_base_url"`
 
-	go client.writePump()
+	// Filas de envio e keepalive das conexões de live reload
+	WebSocket WebSocketConfig `json:"websocket"`
 
-	for {
-		_, _, err := ws.ReadMessage()
-		if err != nil {
-			clientsMutex.Lock()
-			delete(clients, client)
-			clientsMutex.Unlock()
-			break
-		}
-	}
-}
+	// Origens e token aceitos nas conexões de live reload
+	LiveReloadAuth LiveReloadAuthConfig `json:"live_reload_auth"`
 
-// writePump envia mensagens do canal 'send' do cliente para a conexão WebSocket
-func (c *Client) writePump() {
-	defer c.conn.Close()
-	for {
-		select {
-		case message, ok := <-c.send:
-			if !ok {
-				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
-				return
-			}
-			if err := c.conn.WriteMessage(websocket.TextMessage, message); err != nil {
-				return
-			}
-		}
-	}
+	// Injeção em páginas com Content-Security-Policy: "nonce" (padrão) ou "external"
+	CSPMode string `json:"csp_mode"`
+
+	// Trechos injetados por caminho de página (inject_js_path/inject_css_path viram regras)
+	Injections []InjectionRule `json:"injections"`
+
+	// Variáveis de arquivos .env expostas às páginas como window.__ENV__
+	Env EnvConfig `json:"env"`
+
+	// Cache: "dev" (padrão, sem cache) ou "preview" (ETag, 304 e Cache-Control por regra)
+	CacheMode  string      `json:"cache_mode"`
+	CacheRules []CacheRule `json:"cache_rules"`
+
+	// Codificações, tipos e tamanho mínimo da compressão (habilitada por gzip_enabled)
+	Compression CompressionConfig `json:"compression"`
+
+	// Cache em memória dos arquivos estáticos e de suas versões comprimidas
+	AssetCache AssetCacheConfig `json:"asset_cache"`
+
+	// Política CORS: origens, credenciais, cabeçalhos e exceções por caminho
+	CORS CORSConfig `json:"cors"`
+
+	// Cabeçalhos de resposta por caminho (set/append/remove e presets)
+	Headers []HeaderRule `json:"headers"`
 }
 
-// handleMessages envia mensagens do canal de broadcast para todos os clientes conectados
-func handleMessages() {
-	for {
-		message := <-broadcast
-		clientsMutex.Lock()
-		for client := range clients {
-			select {
-			case client.send <- message:
-			default:
-				close(client.send)
-				delete(clients, client)
-			}
-		}
-		clientsMutex.Unlock()
-	}
+// Global para o upgrader de WebSocket
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	CheckOrigin: func(r *http.Request) bool {
+		return liveReloadAuth.originAllowed(r)
+	},
 }
 
-// executeCommandWebhook executa um comando externo
-func executeCommandWebhook(rule CommandWebhookRule, eventDetails map[string]string) {
+var serverStartTime = time.Now() // Para o endpoint /api/status
+
+// executeCommandWebhook executa um comando externo como um job
+func executeCommandWebhook(rule CommandWebhookRule, eventDetails map[string]string) *Job {
 	cmdArgs := make([]string, len(rule.Args))
 	for i, arg := range rule.Args {
 		replacedArg := arg
@@ -162,13 +149,8 @@ func executeCommandWebhook(rule CommandWebhookRule, eventDetails map[string]stri
 		cmdArgs[i] = replacedArg
 	}
 
-	cmd := exec.Command(rule.Command, cmdArgs...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
 	log.Printf("Executando comando webhook: %s %v", rule.Command, cmdArgs)
-	if err := cmd.Run(); err != nil {
-		log.Printf("Erro ao executar comando webhook '%s': %v", rule.Command, err)
-	}
+	return jobs.start("webhook:"+rule.Event, rule.Command, cmdArgs)
 }
 
 // sendNotificationWebhook envia um POST para a URL de notificação
@@ -223,6 +205,7 @@ func watchFiles(dir string, debounceMs int, excludeDirs []string, notificationWe
 				if !ok {
 					return
 				}
+				assetCache.invalidate(event.Name)
 				if strings.HasPrefix(filepath.Base(event.Name), ".") || strings.HasSuffix(event.Name, "~") || strings.HasSuffix(event.Name, ".tmp") {
 					continue
 				}
@@ -244,17 +227,29 @@ func watchFiles(dir string, debounceMs int, excludeDirs []string, notificationWe
 						switch ext {
 						case ".css":
 							msgType = "css-update"
-						case ".js":
+						case ".js", ".mjs":
 							msgType = "js-update"
+						case ".png", ".jpg", ".jpeg", ".gif", ".svg", ".webp", ".avif", ".ico", ".bmp":
+							msgType = "image-update"
+						case ".woff", ".woff2", ".ttf", ".otf", ".eot":
+							msgType = "font-update"
 						default:
 							msgType = "reload"
 						}
 
-						message, _ := json.Marshal(map[string]string{
+						dependencies.invalidate(urlPath)
+						var payload interface{} = map[string]string{
 							"type": msgType,
 							"path": urlPath,
-						})
-						broadcast <- message
+						}
+						if msgType == "js-update" {
+							if hmr, ok := modules.handleChange(urlPath); ok {
+								msgType = hmr.Type
+								payload = hmr
+							}
+						}
+						message, _ := json.Marshal(payload)
+						hub.publish(outboundMessage{data: message, target: func(c *Client) bool { return c.dependsOn(urlPath) }})
 						log.Printf("Mudança detectada em %s, enviando %s", event.Name, msgType)
 
 						eventDetails := map[string]string{
@@ -323,141 +318,159 @@ func loggingMiddleware(next http.Handler) http.Handler {
 	})
 }
 
-// noCacheMiddleware adiciona cabeçalhos para prevenir cache em requisições
-func noCacheMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
-		w.Header().Set("Pragma", "no-cache")
-		w.Header().Set("Expires", "0")
-		next.ServeHTTP(w, r)
-	})
+// htmlInjector insere o cliente de live reload e as injeções configuradas em páginas HTML.
+// Em páginas com CSP, o modo "nonce" libera as tags injetadas na política; o modo
+// "external" não altera a política e injeta JS/CSS como arquivos servidos sob o prefixo reservado.
+type htmlInjector struct {
+	reservedPrefix string
+	cspMode        string
 }
 
-// corsMiddleware adiciona os cabeçalhos CORS necessários
-func corsMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-		w.Header().Set("Access-Control-Allow-Credentials", "true")
+// injection é a injeção em andamento em uma resposta HTML
+type injection struct {
+	in        *htmlInjector
+	r         *http.Request
+	items     []renderedInjection
+	nonce     string
+	hasPolicy bool
+}
 
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusOK)
-			return
-		}
-		next.ServeHTTP(w, r)
-	})
+func (inj *injection) rewrite() cspRewrite {
+	return cspRewrite{nonce: inj.nonce, connectSrcs: liveReloadConnectSources(inj.r), inlineStyle: hasInlineStyle(inj.items)}
 }
 
-// responseRecorder é um http.ResponseWriter que armazena o status e o corpo da resposta.
-type responseRecorder struct {
-	http.ResponseWriter
-	StatusCode int
-	Body       *bytes.Buffer
-	Headers    http.Header
+// begin inicia a injeção; deve ser chamado antes de enviar os cabeçalhos, que podem ter a CSP reescrita
+func (in *htmlInjector) begin(r *http.Request, header http.Header) *injection {
+	inj := &injection{in: in, r: r, items: injections.forPage(r.URL.Path)}
+	if in.cspMode == cspModeNonce {
+		inj.nonce = newCSPNonce()
+	}
+	policies := headerCSP(header)
+	if len(policies) > 0 && inj.nonce != "" {
+		rewriteHeaderCSP(header, inj.rewrite())
+		policies = headerCSP(header)
+		// Com um nonce novo a cada resposta, os validadores do arquivo não descrevem mais o corpo
+		header.Del("ETag")
+		header.Del("Last-Modified")
+	}
+	inj.check(policies)
+	return inj
 }
 
-func newResponseRecorder(w http.ResponseWriter) *responseRecorder {
-	return &responseRecorder{
-		ResponseWriter: w,
-		StatusCode:     http.StatusOK,
-		Body:           new(bytes.Buffer),
-		Headers:        make(http.Header),
+func (inj *injection) check(policies []string) {
+	if len(policies) > 0 {
+		inj.hasPolicy = true
+	}
+	for _, policy := range policies {
+		for _, problem := range cspProblems(policy, inj.in.cspMode == cspModeExternal) {
+			warnCSP(inj.r.URL.Path, problem)
+		}
 	}
 }
 
-func (r *responseRecorder) Header() http.Header {
-	return r.Headers
+// head reescreve as tags <meta> de CSP do trecho do documento até </head>
+func (inj *injection) head(head []byte) []byte {
+	policies := metaCSP(head)
+	if len(policies) > 0 && inj.nonce != "" {
+		head = rewriteMetaCSP(head, inj.rewrite())
+		policies = metaCSP(head)
+	}
+	inj.check(policies)
+	return head
 }
 
-func (r *responseRecorder) WriteHeader(statusCode int) {
-	r.StatusCode = statusCode
+// headSection aplica as injeções de head-start e head-end ao trecho anterior a </head>
+func (inj *injection) headSection(head []byte) []byte {
+	// head costuma ser um prefixo do buffer do documento: limita a capacidade para que os
+	// appends abaixo não sobrescrevam o restante
+	head = inj.head(head[:len(head):len(head)])
+	var start []byte
+	if publicEnv.enabled() {
+		// window.__ENV__ vem primeiro, para estar disponível aos scripts da página
+		start = append(start, publicEnv.tag(inj.in.reservedPrefix, inj.tagNonce(), inj.in.cspMode == cspModeExternal)...)
+	}
+	start = append(start, inj.tags(positionHeadStart)...)
+	if len(start) > 0 {
+		if idx := headOpenEnd(head); idx != -1 {
+			head = bytes.Join([][]byte{head[:idx], []byte("\n"), start, head[idx:]}, nil)
+		} else {
+			head = append(head, start...)
+		}
+	}
+	return append(head, inj.tags(positionHeadEnd)...)
 }
 
-func (r *responseRecorder) Write(b []byte) (int, error) {
-	return r.Body.Write(b)
+// headless aplica as injeções do <head> a um documento sem </head>: elas entram juntas logo
+// após <head ...>, antes de <body ...> ou, sem nenhum dos dois, no início (depois do doctype)
+func (inj *injection) headless(doc []byte) []byte {
+	doc = inj.head(doc[:len(doc):len(doc)])
+	tags := inj.headSection(nil) // Sem trecho de documento, são apenas as tags
+	if len(tags) == 0 {
+		return doc
+	}
+	idx := headOpenEnd(doc)
+	if idx == -1 {
+		idx = indexFold(doc, []byte("<body"))
+	}
+	if idx == -1 {
+		idx = doctypeEnd(doc)
+	}
+	return bytes.Join([][]byte{doc[:idx], tags, doc[idx:]}, nil)
 }
 
-func (r *responseRecorder) CopyTo(w http.ResponseWriter) {
-	for k, v := range r.Headers {
-		w.Header()[k] = v
+// tagNonce é o nonce das tags injetadas; só é usado se a página tiver CSP
+func (inj *injection) tagNonce() string {
+	if !inj.hasPolicy {
+		return ""
 	}
-	w.WriteHeader(r.StatusCode)
-	w.Write(r.Body.Bytes())
+	return inj.nonce
 }
 
-// liveReloadInjector injeta o script de live reload
-func liveReloadInjector(injectedJSContent, injectedCSSContent string, next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path == "/ws" || r.Method != http.MethodGet {
-			next.ServeHTTP(w, r)
-			return
+// tags retorna o HTML das injeções de uma posição
+func (inj *injection) tags(position string) []byte {
+	var buf bytes.Buffer
+	for _, item := range inj.items {
+		if item.position == position {
+			buf.WriteString(item.tag(inj.in.reservedPrefix, inj.r.URL.Path, inj.tagNonce(), inj.in.cspMode == cspModeExternal))
 		}
+	}
+	return buf.Bytes()
+}
 
-		recorder := newResponseRecorder(w)
-		next.ServeHTTP(recorder, r)
-
-		if strings.Contains(recorder.Header().Get("Content-Type"), "text/html") && recorder.StatusCode == http.StatusOK {
-			body := recorder.Body.Bytes()
-
-			liveReloadAndHMRScript := fmt.Sprintf(`
-            <script>
-                var ws = new WebSocket("ws://%s/ws");
-                ws.onmessage = function(event) {
-                    var message = JSON.parse(event.data);
-                    if (message.type === "reload") {
-                        location.reload();
-                    } else if (message.type === "css-update") {
-                        var link = document.querySelector('link[href*="' + message.path + '"]');
-                        if (link) {
-                            var newHref = message.path + '?v=' + new Date().getTime();
-                            link.href = newHref;
-                        } else {
-                            location.reload();
-                        }
-                    } else if (message.type === "js-update") {
-                        var script = document.querySelector('script[src*="' + message.path + '"]');
-                        if (script) {
-                            var newScript = document.createElement('script');
-                            newScript.src = message.path + '?v=' + new Date().getTime();
-                            newScript.async = true;
-                            script.parentNode.replaceChild(newScript, script);
-                        } else {
-                            location.reload();
-                        }
-                    }
-                };
-            </script>
-            `, r.Host)
-
-			var customInjections bytes.Buffer
-			if injectedCSSContent != "" {
-				customInjections.WriteString(fmt.Sprintf("<style>\n%s\n</style>\n", injectedCSSContent))
-			}
-			if injectedJSContent != "" {
-				customInjections.WriteString(fmt.Sprintf("<script>\n%s\n</script>\n", injectedJSContent))
-			}
+// bodyEnd são as injeções de body-end e o cliente de live reload, inseridos antes de </body>
+func (inj *injection) bodyEnd() []byte {
+	return append(inj.tags(positionBodyEnd), liveReloadScriptTag(inj.in.reservedPrefix, inj.tagNonce())...)
+}
 
-			if idx := bytes.LastIndex(body, []byte("</head>")); idx != -1 {
-				body = bytes.Join([][]byte{body[:idx], customInjections.Bytes(), body[idx:]}, nil)
-			}
+// inject aplica as injeções em um documento completo (usado nas respostas do proxy)
+func (in *htmlInjector) inject(r *http.Request, header http.Header, body []byte) []byte {
+	inj := in.begin(r, header)
+	if idx := indexFold(body, headCloseTag); idx != -1 {
+		body = bytes.Join([][]byte{inj.headSection(body[:idx]), body[idx:]}, nil)
+	} else {
+		body = inj.headless(body)
+	}
 
-			if idx := bytes.LastIndex(body, []byte("</body>")); idx != -1 {
-				body = bytes.Join([][]byte{body[:idx], []byte(liveReloadAndHMRScript), body[idx:]}, nil)
-			} else {
-				body = bytes.Join([][]byte{body, []byte(liveReloadAndHMRScript)}, nil)
-			}
+	if idx := lastIndexFold(body, bodyCloseTag); idx != -1 {
+		body = bytes.Join([][]byte{body[:idx], inj.bodyEnd(), body[idx:]}, nil)
+	} else {
+		body = bytes.Join([][]byte{body, inj.bodyEnd()}, nil)
+	}
+	return body
+}
 
-			for k, v := range recorder.Headers {
-				w.Header()[k] = v
-			}
-			w.Header().Set("Content-Length", fmt.Sprintf("%d", len(body)))
-			w.WriteHeader(recorder.StatusCode)
-			w.Write(body)
+// liveReloadInjector injeta o script de live reload nas páginas HTML servidas,
+// reescrevendo a resposta em streaming (apenas o <head> fica em memória)
+func liveReloadInjector(injector *htmlInjector, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isReservedPath(injector.reservedPrefix, r.URL.Path) || r.Method != http.MethodGet {
+			next.ServeHTTP(w, r)
 			return
 		}
 
-		recorder.CopyTo(w)
+		iw := &injectingWriter{ResponseWriter: w, r: r, injector: injector}
+		next.ServeHTTP(iw, r)
+		iw.finish()
 	})
 }
 
@@ -467,17 +480,21 @@ func spaFallbackMiddleware(serveDir string, enabled bool, next http.Handler) htt
 		return next
 	}
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		recorder := newResponseRecorder(w)
-		next.ServeHTTP(recorder, r)
+		indexPath := filepath.Join(serveDir, "index.html")
+		if strings.Contains(filepath.Base(r.URL.Path), ".") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if _, err := os.Stat(indexPath); err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
 
-		if recorder.StatusCode == http.StatusNotFound && !strings.Contains(filepath.Base(r.URL.Path), ".") && r.URL.Path != "/ws" {
-			indexPath := filepath.Join(serveDir, "index.html")
-			if _, err := os.Stat(indexPath); err == nil {
-				http.ServeFile(w, r, indexPath)
-				return
-			}
+		nf := &notFoundInterceptor{ResponseWriter: w}
+		next.ServeHTTP(nf, r)
+		if nf.finish() {
+			http.ServeFile(w, r, indexPath)
 		}
-		recorder.CopyTo(w)
 	})
 }
 
@@ -487,18 +504,19 @@ func customErrorPageMiddleware(custom404Path string, serveDir string, next http.
 		return next
 	}
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		recorder := newResponseRecorder(w)
-		next.ServeHTTP(recorder, r)
-		if recorder.StatusCode == http.StatusNotFound {
-			full404Path := filepath.Join(serveDir, custom404Path)
-			if _, err := os.Stat(full404Path); err == nil {
-				w.Header().Set("Content-Type", "text/html; charset=utf-8")
-				w.WriteHeader(http.StatusNotFound)
-				http.ServeFile(w, r, full404Path)
-				return
-			}
+		full404Path := filepath.Join(serveDir, custom404Path)
+		if _, err := os.Stat(full404Path); err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		nf := &notFoundInterceptor{ResponseWriter: w}
+		next.ServeHTTP(nf, r)
+		if nf.finish() {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.WriteHeader(http.StatusNotFound)
+			http.ServeFile(w, r, full404Path)
 		}
-		recorder.CopyTo(w)
 	})
 }
 
@@ -530,43 +548,8 @@ func (fs noDirListingFileSystem) Open(name string) (http.File, error) {
 	return f, nil
 }
 
-// gzipWriter é um ResponseWriter que comprime a saída usando gzip
-type gzipWriter struct {
-	http.ResponseWriter
-	Writer *gzip.Writer
-}
-
-func (g *gzipWriter) Write(data []byte) (int, error) {
-	return g.Writer.Write(data)
-}
-
-func (g *gzipWriter) WriteHeader(statusCode int) {
-	g.ResponseWriter.Header().Del("Content-Length")
-	g.ResponseWriter.WriteHeader(statusCode)
-}
-
-// gzipMiddleware comprime a resposta
-func gzipMiddleware(enabled bool, next http.Handler) http.Handler {
-	if !enabled {
-		return next
-	}
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
-			next.ServeHTTP(w, r)
-			return
-		}
-
-		w.Header().Set("Content-Encoding", "gzip")
-		gz := gzip.NewWriter(w)
-		defer gz.Close()
-
-		gzw := &gzipWriter{ResponseWriter: w, Writer: gz}
-		next.ServeHTTP(gzw, r)
-	})
-}
-
 // reverseProxyMiddleware encaminha requisições
-func reverseProxyMiddleware(proxyRules []ProxyRule, next http.Handler) http.Handler {
+func reverseProxyMiddleware(proxyRules []ProxyRule, injector *htmlInjector, headerRules []HeaderRule, next http.Handler) http.Handler {
 	if len(proxyRules) == 0 {
 		return next
 	}
@@ -586,13 +569,22 @@ func reverseProxyMiddleware(proxyRules []ProxyRule, next http.Handler) http.Hand
 			originalDirector(req)
 			req.URL.Path = strings.TrimPrefix(req.URL.Path, rule.Path)
 		}
+		inject := rule.Inject
+		injectHTML := injectProxiedHTML(injector)
+		proxy.ModifyResponse = func(resp *http.Response) error {
+			applyHeaderRules(headerRules, inboundRequest(resp.Request).URL.Path, resp.Header)
+			if inject {
+				return injectHTML(resp)
+			}
+			return nil
+		}
 		proxies[rule.Path] = proxy
 	}
 
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		for pathPrefix, proxy := range proxies {
 			if strings.HasPrefix(r.URL.Path, pathPrefix) {
-				proxy.ServeHTTP(w, r)
+				proxy.ServeHTTP(w, withInboundRequest(r))
 				return
 			}
 		}
@@ -621,19 +613,6 @@ func rewriteRedirectMiddleware(rewrites []RewriteRule, redirects []RedirectRule,
 	})
 }
 
-// readInjectedFileContent lê o conteúdo de um arquivo para injeção.
-func readInjectedFileContent(filePath string) string {
-	if filePath == "" {
-		return ""
-	}
-	content, err := os.ReadFile(filePath)
-	if err != nil {
-		log.Printf("Aviso: não foi possível ler o arquivo para injeção '%s': %v", filePath, err)
-		return ""
-	}
-	return string(content)
-}
-
 // loadConfigFromFile lê a configuração de um arquivo JSON.
 func loadConfigFromFile(filePath string, cfg *Config) error {
 	if filePath == "" {
@@ -649,9 +628,34 @@ func loadConfigFromFile(filePath string, cfg *Config) error {
 	return nil
 }
 
-// apiAuthMiddleware verifica o token de autenticação para endpoints da API
+// checkAPIRequest protege as rotas da API que alteram estado contra CSRF: exige JSON (o que força
+// um preflight em requisições de outras páginas) e recusa origens diferentes da do próprio servidor.
+func checkAPIRequest(r *http.Request) (int, string) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		return http.StatusUnsupportedMediaType, "Content-Type deve ser application/json"
+	}
+	if origin := r.Header.Get("Origin"); origin != "" {
+		u, err := url.Parse(origin)
+		if err != nil || !strings.EqualFold(u.Host, r.Host) || (u.Scheme == "https") != (r.TLS != nil) {
+			return http.StatusForbidden, "Origem não permitida"
+		}
+	}
+	return 0, ""
+}
+
+// apiAuthMiddleware verifica o token de autenticação para endpoints da API.
+// Métodos que alteram estado passam antes pela proteção contra CSRF, com ou sem token.
 func apiAuthMiddleware(apiToken string, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			if status, message := checkAPIRequest(r); status != 0 {
+				log.Printf("Requisição %s %s recusada de %s (Origin %q): %s", r.Method, r.URL.Path, r.RemoteAddr, r.Header.Get("Origin"), message)
+				http.Error(w, message, status)
+				return
+			}
+		}
+
 		if apiToken == "" {
 			next.ServeHTTP(w, r)
 			return
@@ -687,13 +691,14 @@ func main() {
 		injectCSSPathFlag          = flag.String("inject-css", "", "Caminho para um arquivo CSS a ser injetado.")
 		spaFallbackEnabledFlag     = flag.Bool("spa-fallback", false, "Habilita o fallback para index.html para SPAs.")
 		dirListingEnabledFlag      = flag.Bool("enable-dir-listing", false, "Habilita a listagem de diretórios.")
-		gzipEnabledFlag            = flag.Bool("enable-gzip", false, "Habilita a compressão Gzip.")
+		gzipEnabledFlag            = flag.Bool("enable-gzip", false, "Habilita a compressão (br, zstd e gzip) e os arquivos pré-comprimidos (.br, .zst, .gz).")
 		custom404PagePathFlag      = flag.String("404-page", "", "Caminho para uma página 404 personalizada.")
 		watchDebounceMsFlag        = flag.Int("watch-debounce-ms", 100, "Tempo de debounce para o watcher (ms).")
 		watchExcludeDirsFlag       = flag.String("watch-exclude-dirs", "", "Diretórios para excluir do watcher (separados por vírgula).")
 		logFilePathFlag            = flag.String("log-file", "server.log", "Caminho para o arquivo de log. Padrão: server.log")
 		apiTokenFlag               = flag.String("api-token", "", "Token de autenticação para a API.")
 		notificationWebhookURLFlag = flag.String("notification-webhook-url", "", "URL para webhooks de notificação.")
+		unsafeCommandsFlag         = flag.Bool("unsafe-arbitrary-commands", false, "INSEGURO: permite executar comandos arbitrários via /api/command (somente a partir do loopback).")
 	)
 
 	flag.Parse()
@@ -744,6 +749,7 @@ func main() {
 	cfg.LogFilePath = *logFilePathFlag
 	cfg.APIToken = *apiTokenFlag
 	cfg.NotificationWebhookURL = *notificationWebhookURLFlag
+	cfg.UnsafeArbitraryCommands = cfg.UnsafeArbitraryCommands || *unsafeCommandsFlag
 
 
 	if cfg.LogFilePath != "" {
@@ -758,50 +764,116 @@ func main() {
 		log.Fatalf("Erro fatal: Diretório a ser servido '%s' não encontrado. Por favor, crie-o ou especifique um diretório válido.", cfg.ServeDir)
 	}
     
-	injectedJSContent := readInjectedFileContent(cfg.InjectJSPath)
-	injectedCSSContent := readInjectedFileContent(cfg.InjectCSSPath)
+	cfg.ReservedPrefix = normalizeReservedPrefix(cfg.ReservedPrefix)
+	base, err := normalizePublicBaseURL(cfg.PublicBaseURL)
+	if err != nil {
+		log.Fatalf("Erro fatal: %v", err)
+	}
+	publicBaseURL = base
+	dependencies = newDependencyGraph(cfg.ServeDir, cfg.SPAFallbackEnabled)
+	modules = newModuleGraph(cfg.ServeDir)
+	if err := hub.configure(cfg.WebSocket); err != nil {
+		log.Fatalf("Erro fatal: configuração de websocket inválida: %v", err)
+	}
+	liveReloadAuth = newLiveReloadAccess(cfg.LiveReloadAuth)
+	switch cfg.CSPMode {
+	case "":
+		cfg.CSPMode = cspModeNonce
+	case cspModeNonce, cspModeExternal:
+	default:
+		log.Fatalf("Erro fatal: csp_mode inválido %q (use %s ou %s)", cfg.CSPMode, cspModeNonce, cspModeExternal)
+	}
+	if err := caching.configure(cfg.CacheMode, cfg.CacheRules); err != nil {
+		log.Fatalf("Erro fatal: %v", err)
+	}
+	compression, err := normalizeCompressionConfig(cfg.Compression)
+	if err != nil {
+		log.Fatalf("Erro fatal: configuração de compressão inválida: %v", err)
+	}
+	cfg.Compression = compression
+	assetCache = newAssetCache(cfg.AssetCache)
+	if err := cfg.CORS.prepare(); err != nil {
+		log.Fatalf("Erro fatal: configuração de CORS inválida: %v", err)
+	}
+	headerRules, err := compileHeaderRules(cfg.Headers)
+	if err != nil {
+		log.Fatalf("Erro fatal: configuração de cabeçalhos inválida: %v", err)
+	}
+	interactionSync.configure(cfg.Sync)
+	browserConsole.configure(cfg.Console, cfg.NotificationWebhookURL)
+	modules.scan()
+	jobs.setLimits(cfg.JobHistoryLimit, cfg.JobOutputLimitBytes)
+
+	if err := compileCommandDefinitions(cfg.Commands); err != nil {
+		log.Fatalf("Erro fatal: configuração de comandos inválida: %v", err)
+	}
+	if cfg.UnsafeArbitraryCommands {
+		log.Printf("AVISO: execução de comandos arbitrários via API habilitada (somente loopback)")
+	}
+
+	sched, err := newScheduler(cfg.CommandWebhooks)
+	if err != nil {
+		log.Fatalf("Erro fatal: agendamento de comandos inválido: %v", err)
+	}
+	schedules = sched
 
-	go handleMessages()
+	injectionRules := append(legacyInjectionRules(cfg.InjectJSPath, cfg.InjectCSSPath), cfg.Injections...)
+	if err := injections.configure(injectionRules, cfg.Port); err != nil {
+		log.Fatalf("Erro fatal: configuração de injeções inválida: %v", err)
+	}
+	injections.watch()
+	if err := publicEnv.configure(cfg.Env); err != nil {
+		log.Fatalf("Erro fatal: configuração de env inválida: %v", err)
+	}
+	publicEnv.watch()
+
+	go hub.run()
 	go watchFiles(cfg.ServeDir, cfg.WatchDebounceMs, cfg.WatchExcludeDirs, cfg.NotificationWebhookURL, cfg.CommandWebhooks)
 
 	mux := http.NewServeMux()
-	mux.HandleFunc("/ws", handleConnections)
+	mux.Handle(cfg.ReservedPrefix+"/", reservedRoutesHandler(cfg.ReservedPrefix))
 
 	apiMux := http.NewServeMux()
 	apiMux.HandleFunc("/api/reload", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost { http.Error(w, "Método não permitido", http.StatusMethodNotAllowed); return }
-		message, _ := json.Marshal(map[string]string{"type": "reload"}); broadcast <- message
+		message, _ := json.Marshal(map[string]string{"type": "reload"}); hub.publish(outboundMessage{data: message})
 		w.WriteHeader(http.StatusOK); w.Write([]byte("Live reload disparado!"))
 	})
 	apiMux.HandleFunc("/api/status", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet { http.Error(w, "Método não permitido", http.StatusMethodNotAllowed); return }
-		status := map[string]interface{}{"status": "running", "uptime": time.Since(serverStartTime).String(), "port": cfg.Port, "serve_dir": cfg.ServeDir, "connected_clients": len(clients)}
+		status := map[string]interface{}{"status": "running", "uptime": time.Since(serverStartTime).String(), "port": cfg.Port, "serve_dir": cfg.ServeDir, "connected_clients": hub.count(), "cache_mode": caching.current(), "asset_cache": assetCache.stats(), "websocket": hub.stats(), "schedules": schedules.status()}
 		json.NewEncoder(w).Encode(status)
 	})
-	apiMux.HandleFunc("/api/command", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost { http.Error(w, "Método não permitido", http.StatusMethodNotAllowed); return }
-		var req struct { Command string `json:"command"`; Args []string `json:"args"` }
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil { http.Error(w, "Requisição inválida", http.StatusBadRequest); return }
-		go func() {
-			cmd := exec.Command(req.Command, req.Args...); cmd.Stdout = os.Stdout; cmd.Stderr = os.Stderr
-			log.Printf("Executando comando via API: %s %v", req.Command, req.Args)
-			if err := cmd.Run(); err != nil { log.Printf("Erro ao executar comando via API '%s': %v", req.Command, err) }
-		}(); w.WriteHeader(http.StatusOK); w.Write([]byte("Comando enviado para execução."))
-	})
+	apiMux.HandleFunc("/api/command", commandAPIHandler(cfg.Commands, cfg.UnsafeArbitraryCommands))
+	apiMux.HandleFunc("/api/commands", commandsListHandler(cfg.Commands))
+	apiMux.HandleFunc("/api/jobs", handleJobsAPI)
+	apiMux.HandleFunc("/api/jobs/", handleJobAPI)
+	apiMux.HandleFunc("/api/schedules", handleSchedulesAPI)
+	apiMux.HandleFunc("/api/schedules/", handleSchedulesAPI)
+	apiMux.HandleFunc("/api/sync", handleSyncAPI)
+	apiMux.HandleFunc("/api/clients", handleClientsAPI)
+	apiMux.HandleFunc("/api/clients/", handleClientsAPI)
+	apiMux.HandleFunc("/api/console", handleConsoleAPI)
+	apiMux.HandleFunc("/api/broadcast", handleBroadcastAPI)
+	apiMux.HandleFunc("/api/cache", handleCacheAPI)
 	mux.Handle("/api/", apiAuthMiddleware(cfg.APIToken, apiMux))
 
 	var fileServerHandler http.Handler
 	if cfg.DirListingEnabled { fileServerHandler = http.FileServer(http.Dir(cfg.ServeDir)) } else { fileServerHandler = http.FileServer(noDirListingFileSystem{http.Dir(cfg.ServeDir)}) }
 
-	handler := fileServerHandler
+	handler := hmrModuleMiddleware(cfg.ServeDir, etagMiddleware(cfg.ServeDir, cfg.CSPMode == cspModeNonce, precompressedMiddleware(cfg.GzipEnabled, cfg.ServeDir, cfg.Compression, assetCacheMiddleware(cfg.ServeDir, cfg.GzipEnabled, cfg.Compression, fileServerHandler))))
+	handler = buildOnRequestMiddleware(cfg.ServeDir, cfg.BuildOnRequest, handler)
+	handler = dependencyTrackingMiddleware(handler)
 	handler = customErrorPageMiddleware(cfg.Custom404PagePath, cfg.ServeDir, handler)
 	handler = spaFallbackMiddleware(cfg.ServeDir, cfg.SPAFallbackEnabled, handler)
-	handler = liveReloadInjector(injectedJSContent, injectedCSSContent, handler)
-	handler = reverseProxyMiddleware(cfg.ProxyRules, handler)
+	handler = headerRulesMiddleware(headerRules, handler)
+	injector := &htmlInjector{reservedPrefix: cfg.ReservedPrefix, cspMode: cfg.CSPMode}
+	handler = liveReloadInjector(injector, handler)
+	handler = reverseProxyMiddleware(cfg.ProxyRules, injector, headerRules, handler)
 	handler = rewriteRedirectMiddleware(cfg.Rewrites, cfg.Redirects, handler)
-	handler = corsMiddleware(handler)
-	handler = noCacheMiddleware(handler)
-	handler = gzipMiddleware(cfg.GzipEnabled, handler)
+	handler = corsMiddleware(cfg.CORS, cfg.ProxyRules, handler)
+	handler = cachingMiddleware(handler)
+	handler = compressMiddleware(cfg.GzipEnabled, cfg.Compression, handler)
 	handler = loggingMiddleware(handler)
 	mux.Handle("/", handler)
 
@@ -814,6 +886,18 @@ func main() {
         log.Printf("   Logs sendo gravados em: %s", cfg.LogFilePath)
     }
 
+	schedules.start()
+
+	// Os jobs rodam em grupos de processos próprios e não recebem o Ctrl+C do terminal
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		sig := <-signals
+		log.Printf("Sinal %v recebido, encerrando os jobs em execução", sig)
+		jobs.cancelAll()
+		os.Exit(1)
+	}()
+
 	for _, rule := range cfg.CommandWebhooks {
 		if rule.Event == "server_start" {
 			go executeCommandWebhook(rule, map[string]string{ "timestamp": time.Now().Format(time.RFC3339), "port": fmt.Sprintf("%d", cfg.Port), "serve_dir": cfg.ServeDir, })
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// LiveReloadAuthConfig restringe quem pode se conectar às rotas de live reload (/ws, /events, /poll, /send)
+type LiveReloadAuthConfig struct {
+	// Origens aceitas além do host servido e seus aliases locais (ex: "http://app.test:3000"); "*" aceita qualquer uma
+	AllowedOrigins []string `json:"allowed_origins"`
+	// Exige o token da sessão, embutido pelo injetor na tag do cliente
+	RequireToken bool `json:"require_token"`
+}
+
+// liveReloadAccess guarda a configuração de acesso e o token desta execução do servidor
+type liveReloadAccess struct {
+	allowAny       bool
+	allowedOrigins map[string]bool
+	localAliases   map[string]bool
+	requireToken   bool
+	token          string
+}
+
+// Controle de acesso global, configurado em main
+var liveReloadAuth = newLiveReloadAccess(LiveReloadAuthConfig{})
+
+func newLiveReloadAccess(cfg LiveReloadAuthConfig) *liveReloadAccess {
+	a := &liveReloadAccess{
+		allowedOrigins: make(map[string]bool),
+		localAliases:   localHostAliases(),
+		requireToken:   cfg.RequireToken,
+		token:          newSessionToken(),
+	}
+	for _, origin := range cfg.AllowedOrigins {
+		origin = strings.TrimRight(strings.ToLower(strings.TrimSpace(origin)), "/")
+		if origin == "*" {
+			a.allowAny = true
+		}
+		a.allowedOrigins[origin] = true
+	}
+	return a
+}
+
+func newSessionToken() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// localHostAliases retorna os nomes e IPs pelos quais esta máquina é acessada na rede local
+func localHostAliases() map[string]bool {
+	aliases := map[string]bool{"localhost": true, "127.0.0.1": true, "::1": true}
+	if hostname, err := os.Hostname(); err == nil {
+		hostname = strings.ToLower(hostname)
+		aliases[hostname] = true
+		aliases[hostname+".local"] = true
+	}
+	if addrs, err := net.InterfaceAddrs(); err == nil {
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok {
+				aliases[ipNet.IP.String()] = true
+			}
+		}
+	}
+	return aliases
+}
+
+// splitHostPort separa host e porta, aceitando endereços sem porta
+func splitHostPort(hostport string) (string, string) {
+	host, port, err := net.SplitHostPort(hostport)
+	if err != nil {
+		return strings.Trim(hostport, "[]"), ""
+	}
+	return host, port
+}
+
+// originAllowed verifica o cabeçalho Origin. Requisições sem Origin (ferramentas fora do
+// navegador) são aceitas: o risco é uma página qualquer se conectar a partir do navegador.
+func (a *liveReloadAccess) originAllowed(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || a.allowAny {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	if a.allowedOrigins[strings.ToLower(u.Scheme+"://"+u.Host)] || strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	// Mesmo servidor acessado por outro nome (localhost, IP da rede local, hostname)
+	originHost, originPort := splitHostPort(strings.ToLower(u.Host))
+	_, servedPort := splitHostPort(r.Host)
+	return a.localAliases[originHost] && originPort == servedPort
+}
+
+// tokenValid verifica o token da sessão (?token=) quando exigido
+func (a *liveReloadAccess) tokenValid(r *http.Request) bool {
+	if !a.requireToken {
+		return true
+	}
+	return subtle.ConstantTimeCompare([]byte(r.URL.Query().Get("token")), []byte(a.token)) == 1
+}
+
+// scriptToken é o token embutido na tag do cliente; vazio quando não é exigido
+func (a *liveReloadAccess) scriptToken() string {
+	if !a.requireToken {
+		return ""
+	}
+	return a.token
+}
+
+// liveReloadAuthMiddleware recusa (403) conexões de origens não permitidas ou sem o token
+func liveReloadAuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		reason := ""
+		if !liveReloadAuth.originAllowed(r) {
+			reason = "origem não permitida"
+		} else if !liveReloadAuth.tokenValid(r) {
+			reason = "token ausente ou inválido"
+		}
+		if reason != "" {
+			log.Printf("Conexão de live reload recusada: %s %s de %s (Origin: %q): %s", r.Method, r.URL.Path, r.RemoteAddr, r.Header.Get("Origin"), reason)
+			http.Error(w, "Acesso negado", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+)
+
+// setProcessGroup não tem equivalente simples no Windows: apenas o processo direto é encerrado
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup encerra o processo
+func killProcessGroup(process *os.Process) error {
+	if err := process.Kill(); err != nil && err != os.ErrProcessDone {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// proxyEncodings são os Content-Encoding que o proxy sabe descomprimir e comprimir de novo
+var proxyEncodings = map[string]bool{"": true, "identity": true, "gzip": true, "x-gzip": true, "deflate": true, "br": true, "zstd": true}
+
+// decodeReader descomprime um corpo conforme o Content-Encoding do backend
+func decodeReader(encoding string, body io.Reader) (io.Reader, error) {
+	switch encoding {
+	case "", "identity":
+		return body, nil
+	case "gzip", "x-gzip":
+		return gzip.NewReader(body)
+	case "deflate":
+		return flate.NewReader(body), nil
+	case "br":
+		return brotli.NewReader(body), nil
+	case "zstd":
+		decoder, err := zstd.NewReader(body, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	}
+	return nil, fmt.Errorf("Content-Encoding não suportado: %s", encoding)
+}
+
+// flushWriteCloser é um compressor que permite enviar o que já foi comprimido
+type flushWriteCloser interface {
+	io.WriteCloser
+	Flush() error
+}
+
+// nopFlushWriteCloser é o "compressor" de respostas sem Content-Encoding
+type nopFlushWriteCloser struct{ io.Writer }
+
+func (nopFlushWriteCloser) Flush() error { return nil }
+func (nopFlushWriteCloser) Close() error { return nil }
+
+// encodeWriter comprime novamente o corpo com a mesma codificação recebida
+func encodeWriter(encoding string, w io.Writer) (flushWriteCloser, error) {
+	switch encoding {
+	case "", "identity":
+		return nopFlushWriteCloser{w}, nil
+	case "gzip", "x-gzip":
+		return gzip.NewWriter(w), nil
+	case "deflate":
+		return flate.NewWriter(w, flate.DefaultCompression)
+	case "br":
+		return brotli.NewWriter(w), nil
+	case "zstd":
+		return zstd.NewWriter(w, zstd.WithEncoderConcurrency(1))
+	}
+	return nil, fmt.Errorf("Content-Encoding não suportado: %s", encoding)
+}
+
+// bodyWriter adapta o corpo reescrito ao http.ResponseWriter usado pelo injectingWriter
+type bodyWriter struct{ io.Writer }
+
+func (bodyWriter) Header() http.Header { return http.Header{} }
+func (bodyWriter) WriteHeader(int)     {}
+
+// injectStream descomprime o corpo do backend, aplica a injeção e o comprime de novo trecho a
+// trecho, enviando cada um assim que lido: páginas renderizadas em streaming chegam ao navegador
+// aos poucos, e só o <head> (ou o final a partir de </body>) fica em memória
+func injectStream(dst io.Writer, src io.Reader, encoding string, inj *injection) error {
+	decoded, err := decodeReader(encoding, src)
+	if err != nil {
+		return err
+	}
+	if closer, ok := decoded.(io.Closer); ok {
+		defer closer.Close()
+	}
+	encoder, err := encodeWriter(encoding, dst)
+	if err != nil {
+		return err
+	}
+	iw := &injectingWriter{ResponseWriter: bodyWriter{encoder}, state: injectHead, inj: inj}
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := decoded.Read(buf)
+		if n > 0 {
+			if _, err := iw.Write(buf[:n]); err != nil {
+				return err
+			}
+			if err := encoder.Flush(); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	iw.finish()
+	return encoder.Close()
+}
+
+// Chave de contexto com a requisição recebida do navegador, antes do Director do proxy
+type inboundRequestKey struct{}
+
+func withInboundRequest(r *http.Request) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), inboundRequestKey{}, r))
+}
+
+// inboundRequest retorna a requisição original de uma requisição encaminhada ao backend.
+// A requisição de saída não tem TLS e tem o prefixo da regra removido do caminho.
+func inboundRequest(r *http.Request) *http.Request {
+	if inbound, ok := r.Context().Value(inboundRequestKey{}).(*http.Request); ok {
+		return inbound
+	}
+	return r
+}
+
+// injectProxiedHTML injeta o live reload nas páginas HTML vindas do backend, em streaming.
+// Respostas que não são HTML seguem sem serem lidas.
+func injectProxiedHTML(injector *htmlInjector) func(*http.Response) error {
+	return func(resp *http.Response) error {
+		req := inboundRequest(resp.Request)
+		if req.Method != http.MethodGet || resp.StatusCode != http.StatusOK || !strings.Contains(resp.Header.Get("Content-Type"), "text/html") {
+			return nil
+		}
+		encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+		if strings.Contains(encoding, ",") {
+			return nil // Codificações encadeadas: entrega sem alterar
+		}
+		if !proxyEncodings[encoding] {
+			log.Printf("Aviso: não foi possível injetar o live reload em %s: Content-Encoding não suportado: %s", req.URL.Path, encoding)
+			return nil
+		}
+
+		inj := injector.begin(req, resp.Header)
+		// O corpo muda e o tamanho final só é conhecido no fim: validadores do backend não valem mais
+		resp.Header.Del("Content-Length")
+		resp.Header.Del("ETag")
+		resp.Header.Del("Content-MD5")
+		resp.ContentLength = -1
+
+		body := resp.Body
+		reader, writer := io.Pipe()
+		resp.Body = reader
+		go func() {
+			defer body.Close()
+			if err := injectStream(writer, body, encoding, inj); err != nil {
+				if err != io.ErrClosedPipe { // O navegador desistiu da resposta
+					log.Printf("Aviso: erro ao injetar o live reload em %s: %v", req.URL.Path, err)
+				}
+				writer.CloseWithError(err)
+				return
+			}
+			writer.Close()
+		}()
+		return nil
+	}
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+)
+
+var (
+	headCloseTag = []byte("</head>")
+	bodyCloseTag = []byte("</body>")
+)
+
+// maxBufferedHead limita o trecho mantido em memória enquanto procuramos </head>
+const maxBufferedHead = 256 * 1024
+
+// maxBufferedTail limita o trecho retido a partir do último </body> visto, à espera de outro
+// (um "</body>" dentro de um <script>, por exemplo); além disso o trecho segue sem injeção
+const maxBufferedTail = 256 * 1024
+
+// indexFold é bytes.Index sem diferenciar maiúsculas (apenas ASCII, como as tags HTML)
+func indexFold(s, sep []byte) int {
+	for i := 0; i+len(sep) <= len(s); i++ {
+		if bytes.EqualFold(s[i:i+len(sep)], sep) {
+			return i
+		}
+	}
+	return -1
+}
+
+// headOpenEnd retorna a posição logo após a tag <head ...>, ou -1
+func headOpenEnd(b []byte) int {
+	for from := 0; ; {
+		idx := indexFold(b[from:], []byte("<head"))
+		if idx == -1 {
+			return -1
+		}
+		idx += from + len("<head")
+		if idx < len(b) && (b[idx] == '>' || b[idx] == ' ' || b[idx] == '\t' || b[idx] == '\n' || b[idx] == '\r') {
+			if end := bytes.IndexByte(b[idx:], '>'); end != -1 {
+				return idx + end + 1
+			}
+			return -1
+		}
+		from = idx
+	}
+}
+
+// doctypeEnd retorna a posição logo após um <!DOCTYPE ...> no início do documento, ou 0
+func doctypeEnd(b []byte) int {
+	trimmed := bytes.TrimLeft(b, " \t\r\n\ufeff")
+	if len(trimmed) < len("<!doctype") || !bytes.EqualFold(trimmed[:len("<!doctype")], []byte("<!doctype")) {
+		return 0
+	}
+	if end := bytes.IndexByte(trimmed, '>'); end != -1 {
+		return len(b) - len(trimmed) + end + 1
+	}
+	return 0
+}
+
+// lastIndexFold é bytes.LastIndex sem diferenciar maiúsculas
+func lastIndexFold(s, sep []byte) int {
+	for i := len(s) - len(sep); i >= 0; i-- {
+		if bytes.EqualFold(s[i:i+len(sep)], sep) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Estados do injectingWriter
+const (
+	injectUndecided   = iota // Cabeçalhos ainda não enviados
+	injectPassthrough        // Não é HTML: tudo segue direto
+	injectHead               // Acumulando até </head>
+	injectBody               // Procurando o último </body>
+	injectDone               // Script inserido no final da resposta
+)
+
+// injectingWriter insere as tags do live reload enquanto a resposta é escrita.
+// Só respostas HTML 200 são alteradas; o restante (vídeos, Range, etc.) passa direto.
+type injectingWriter struct {
+	http.ResponseWriter
+	r        *http.Request
+	injector *htmlInjector
+
+	state int
+	inj   *injection
+	buf   []byte // <head> acumulado, ou o trecho a partir do último </body> (ou de parte dele)
+}
+
+func (w *injectingWriter) WriteHeader(statusCode int) {
+	if w.state != injectUndecided {
+		return
+	}
+	header := w.ResponseWriter.Header()
+	if statusCode == http.StatusOK && strings.Contains(header.Get("Content-Type"), "text/html") && header.Get("Content-Encoding") == "" {
+		w.state = injectHead
+		w.inj = w.injector.begin(w.r, header)
+		header.Del("Content-Length")
+	} else {
+		w.state = injectPassthrough
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *injectingWriter) Write(p []byte) (int, error) {
+	if w.state == injectUndecided {
+		w.WriteHeader(http.StatusOK)
+	}
+	switch w.state {
+	case injectHead:
+		// Procura apenas a partir do que pode conter a tag (trechos anteriores já foram verificados)
+		from := len(w.buf) - (len(headCloseTag) - 1)
+		if from < 0 {
+			from = 0
+		}
+		w.buf = append(w.buf, p...)
+		if idx := indexFold(w.buf[from:], headCloseTag); idx != -1 {
+			idx += from
+			rest := w.buf[idx:]
+			if _, err := w.ResponseWriter.Write(w.inj.headSection(w.buf[:idx])); err != nil {
+				return 0, err
+			}
+			w.state, w.buf = injectBody, nil
+			return len(p), w.writeBody(rest)
+		}
+		if len(w.buf) > maxBufferedHead {
+			// Documento sem </head> (ou com um <head> enorme): as injeções do head entram no que já temos
+			head := w.inj.headless(w.buf)
+			w.state, w.buf = injectBody, nil
+			return len(p), w.writeBody(head)
+		}
+		return len(p), nil
+	case injectBody:
+		return len(p), w.writeBody(p)
+	default:
+		return w.ResponseWriter.Write(p)
+	}
+}
+
+// partialTagSuffix retorna o tamanho do maior final de b que é o início da tag (uma tag partida entre escritas)
+func partialTagSuffix(b, tag []byte) int {
+	for n := len(tag) - 1; n > 0; n-- {
+		if n <= len(b) && bytes.EqualFold(b[len(b)-n:], tag[:n]) {
+			return n
+		}
+	}
+	return 0
+}
+
+// writeBody escreve o trecho retendo o final a partir do último </body> visto, já que o
+// script vai antes do último; sem ele, retém apenas o início de uma tag partida
+func (w *injectingWriter) writeBody(p []byte) error {
+	data := append(w.buf, p...)
+	cut := lastIndexFold(data, bodyCloseTag)
+	if cut == -1 || len(data)-cut > maxBufferedTail {
+		cut = len(data) - partialTagSuffix(data, bodyCloseTag)
+	}
+	w.buf = append([]byte(nil), data[cut:]...)
+	if cut == 0 {
+		return nil
+	}
+	_, err := w.ResponseWriter.Write(data[:cut])
+	return err
+}
+
+// finish escreve o que ficou pendente, com o script antes do último </body> ou, sem ele,
+// no final do documento
+func (w *injectingWriter) finish() {
+	switch w.state {
+	case injectHead:
+		w.buf = w.inj.headless(w.buf)
+		fallthrough
+	case injectBody:
+		data := w.buf
+		w.state, w.buf = injectDone, nil
+		if idx := lastIndexFold(data, bodyCloseTag); idx != -1 {
+			w.ResponseWriter.Write(bytes.Join([][]byte{data[:idx], w.inj.bodyEnd(), data[idx:]}, nil))
+		} else {
+			w.ResponseWriter.Write(append(data, w.inj.bodyEnd()...))
+		}
+	}
+}
+
+func (w *injectingWriter) Flush() {
+	if w.state == injectUndecided {
+		w.WriteHeader(http.StatusOK)
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *injectingWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return hijack(w.ResponseWriter)
+}
+
+// notFoundInterceptor descarta uma resposta 404 para que o middleware sirva outra no lugar
+// (fallback de SPA, página 404 customizada). Outras respostas seguem direto, sem buffer.
+type notFoundInterceptor struct {
+	http.ResponseWriter
+	header      http.Header // Cabeçalhos do handler interno, aplicados só se a resposta não for descartada
+	decided     bool
+	intercepted bool
+}
+
+func (w *notFoundInterceptor) Header() http.Header {
+	if w.decided && !w.intercepted {
+		return w.ResponseWriter.Header()
+	}
+	if w.header == nil {
+		w.header = make(http.Header)
+	}
+	return w.header
+}
+
+func (w *notFoundInterceptor) WriteHeader(statusCode int) {
+	if w.decided {
+		return
+	}
+	w.decided = true
+	if statusCode == http.StatusNotFound {
+		w.intercepted = true
+		return
+	}
+	for k, v := range w.header {
+		w.ResponseWriter.Header()[k] = v
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *notFoundInterceptor) Write(p []byte) (int, error) {
+	if !w.decided {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.intercepted {
+		return len(p), nil
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// finish informa se a resposta foi descartada; se o handler não escreveu nada, envia seus cabeçalhos
+func (w *notFoundInterceptor) finish() bool {
+	if !w.decided {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.intercepted
+}
+
+func (w *notFoundInterceptor) Flush() {
+	if w.intercepted {
+		return
+	}
+	if !w.decided {
+		w.WriteHeader(http.StatusOK)
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *notFoundInterceptor) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return hijack(w.ResponseWriter)
+}
+
+// hijack repassa o Hijack ao ResponseWriter original, se ele suportar
+func hijack(w http.ResponseWriter) (net.Conn, *bufio.ReadWriter, error) {
+	if hijacker, ok := w.(http.Hijacker); ok {
+		return hijacker.Hijack()
+	}
+	return nil, nil, errors.New("hijack não suportado")
+}
+
+// hookWriter chama 'before' uma única vez, logo antes do envio dos cabeçalhos
+type hookWriter struct {
+	http.ResponseWriter
+	before func(statusCode int)
+	called bool
+}
+
+func (w *hookWriter) WriteHeader(statusCode int) {
+	if !w.called {
+		w.called = true
+		w.before(statusCode)
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *hookWriter) Write(p []byte) (int, error) {
+	if !w.called {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// finish chama 'before' se o handler terminou sem escrever nada (o net/http envia 200 depois)
+func (w *hookWriter) finish() {
+	if !w.called {
+		w.called = true
+		w.before(http.StatusOK)
+	}
+}
+
+func (w *hookWriter) Flush() {
+	if !w.called {
+		w.WriteHeader(http.StatusOK)
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *hookWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return hijack(w.ResponseWriter)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestInjectingWriter(t *testing.T) {
+	saved := injections.rules
+	defer func() { injections.rules = saved }()
+	injections.rules = []InjectionRule{
+		{Paths: []string{"/**"}, Position: positionHeadStart, Type: "html", Content: "<!--hs-->"},
+		{Paths: []string{"/**"}, Position: positionHeadEnd, Type: "html", Content: "<!--he-->"},
+		{Paths: []string{"/**"}, Position: positionBodyEnd, Type: "html", Content: "<!--be-->"},
+	}
+	injector := &htmlInjector{reservedPrefix: "/__brhttp", cspMode: cspModeNonce}
+	client := liveReloadScriptTag("/__brhttp", "")
+
+	tests := []struct {
+		name        string
+		contentType string
+		in          string
+		want        string
+	}{
+		{
+			"documento completo", "text/html",
+			"<html><head><title>t</title></head><body><p>x</p></body></html>",
+			"<html><head>\n<!--hs-->\n<title>t</title><!--he-->\n</head><body><p>x</p><!--be-->\n" + client + "</body></html>",
+		},
+		{
+			"último </body>", "text/html",
+			`<head></head><body><script>"</BODY>"</script></body>`,
+			"<head>\n<!--hs-->\n<!--he-->\n</head><body><script>\"</BODY>\"</script><!--be-->\n" + client + "</body>",
+		},
+		{
+			"sem </head>", "text/html",
+			"<body>x</body>",
+			"<!--hs-->\n<!--he-->\n<body>x<!--be-->\n" + client + "</body>",
+		},
+		{
+			"sem </head> com <head>", "text/html",
+			"<html><head><title>t</title><body>x</body>",
+			"<html><head><!--hs-->\n<!--he-->\n<title>t</title><body>x<!--be-->\n" + client + "</body>",
+		},
+		{
+			"sem tags", "text/html; charset=utf-8",
+			"<!DOCTYPE html><p>x",
+			"<!DOCTYPE html><!--hs-->\n<!--he-->\n<p>x<!--be-->\n" + client,
+		},
+		{
+			"não HTML", "text/plain",
+			"<head></head><body></body>",
+			"<head></head><body></body>",
+		},
+	}
+	for _, tt := range tests {
+		// Trechos de vários tamanhos exercitam as tags partidas entre escritas
+		for _, size := range []int{1, 3, 7, len(tt.in)} {
+			rec := httptest.NewRecorder()
+			rec.Header().Set("Content-Type", tt.contentType)
+			w := &injectingWriter{ResponseWriter: rec, r: httptest.NewRequest(http.MethodGet, "/", nil), injector: injector}
+			for in := tt.in; in != ""; {
+				n := size
+				if n > len(in) {
+					n = len(in)
+				}
+				w.Write([]byte(in[:n]))
+				in = in[n:]
+			}
+			w.finish()
+			if got := rec.Body.String(); got != tt.want {
+				t.Errorf("%s (trechos de %d):\nobtido   %q\nesperado %q", tt.name, size, got, tt.want)
+			}
+			if strings.Count(rec.Body.String(), "<!--be-->") > 1 {
+				t.Errorf("%s (trechos de %d): injeção duplicada", tt.name, size)
+			}
+		}
+	}
+}
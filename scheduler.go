@@ -0,0 +1,254 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// scheduledCommand é uma regra de webhook com evento "schedule" em execução no agendador
+type scheduledCommand struct {
+	name     string
+	rule     CommandWebhookRule
+	cron     *cronSchedule
+	interval time.Duration
+
+	mu       sync.Mutex
+	nextRun  time.Time
+	lastRun  time.Time
+	lastJob  *Job
+	starting bool // Entre a verificação e o início do job, para que dois disparos não executem juntos
+	runs     int
+	skipped  int
+	timer    *time.Timer
+}
+
+// scheduleInfo é a representação JSON do estado de um agendamento
+type scheduleInfo struct {
+	Name      string     `json:"name"`
+	Schedule  string     `json:"schedule,omitempty"`
+	Interval  string     `json:"interval,omitempty"`
+	NextRun   *time.Time `json:"next_run,omitempty"`
+	LastRun   *time.Time `json:"last_run,omitempty"`
+	LastJobID string     `json:"last_job_id,omitempty"`
+	LastState string     `json:"last_status,omitempty"`
+	Runs      int        `json:"runs"`
+	Skipped   int        `json:"skipped"`
+}
+
+// scheduler executa os comandos agendados em processo
+type scheduler struct {
+	entries []*scheduledCommand
+	byName  map[string]*scheduledCommand
+}
+
+// Agendador global, preenchido em main a partir da configuração
+var schedules = &scheduler{byName: make(map[string]*scheduledCommand)}
+
+// newScheduler valida as regras com evento "schedule" e monta o agendador
+func newScheduler(rules []CommandWebhookRule) (*scheduler, error) {
+	s := &scheduler{byName: make(map[string]*scheduledCommand)}
+	for _, rule := range rules {
+		if rule.Event != "schedule" {
+			continue
+		}
+		entry := &scheduledCommand{name: rule.Name, rule: rule}
+		if entry.name == "" {
+			entry.name = fmt.Sprintf("schedule-%d", len(s.entries)+1)
+		}
+		if _, exists := s.byName[entry.name]; exists {
+			return nil, fmt.Errorf("nome de agendamento duplicado '%s'", entry.name)
+		}
+
+		switch {
+		case rule.Schedule != "" && rule.Interval != "":
+			return nil, fmt.Errorf("agendamento '%s': use apenas 'schedule' ou 'interval'", entry.name)
+		case rule.Schedule != "":
+			cron, err := parseCronExpression(rule.Schedule)
+			if err != nil {
+				return nil, fmt.Errorf("agendamento '%s': %w", entry.name, err)
+			}
+			entry.cron = cron
+		case rule.Interval != "":
+			interval, err := time.ParseDuration(rule.Interval)
+			if err != nil || interval < time.Second {
+				return nil, fmt.Errorf("agendamento '%s': intervalo inválido '%s' (mínimo 1s)", entry.name, rule.Interval)
+			}
+			entry.interval = interval
+		default:
+			return nil, fmt.Errorf("agendamento '%s' sem 'schedule' nem 'interval'", entry.name)
+		}
+
+		s.entries = append(s.entries, entry)
+		s.byName[entry.name] = entry
+	}
+	return s, nil
+}
+
+// start agenda a primeira execução de cada entrada
+func (s *scheduler) start() {
+	for _, entry := range s.entries {
+		entry.scheduleNext(time.Now())
+		log.Printf("Comando agendado '%s': próxima execução em %s", entry.name, entry.next().Format(time.RFC3339))
+	}
+}
+
+func (e *scheduledCommand) computeNext(from time.Time) time.Time {
+	if e.cron != nil {
+		return e.cron.next(from)
+	}
+	return from.Add(e.interval)
+}
+
+func (e *scheduledCommand) next() time.Time {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.nextRun
+}
+
+func (e *scheduledCommand) scheduleNext(from time.Time) {
+	next := e.computeNext(from)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.nextRun = next
+	if next.IsZero() {
+		log.Printf("Comando agendado '%s' não tem próximas execuções", e.name)
+		return
+	}
+	e.timer = time.AfterFunc(time.Until(next), e.fire)
+}
+
+// fire é chamado pelo timer no horário agendado
+func (e *scheduledCommand) fire() {
+	scheduled := e.next()
+	now := time.Now()
+
+	// Se o processo ficou suspenso e perdemos execuções, registra as que foram puladas
+	missed := 0
+	for t := e.computeNext(scheduled); !t.IsZero() && !t.After(now) && missed < 1000; t = e.computeNext(t) {
+		missed++
+	}
+	if missed > 0 {
+		e.mu.Lock()
+		e.skipped += missed
+		e.mu.Unlock()
+		log.Printf("Comando agendado '%s': %d execução(ões) perdida(s) foram ignoradas", e.name, missed)
+	}
+
+	if _, err := e.trigger("schedule"); err != nil {
+		log.Printf("Comando agendado '%s': %v", e.name, err)
+	}
+	e.scheduleNext(now)
+}
+
+// trigger executa o comando, a menos que a execução anterior ainda esteja em andamento
+func (e *scheduledCommand) trigger(reason string) (*Job, error) {
+	e.mu.Lock()
+	if e.starting {
+		e.skipped++
+		e.mu.Unlock()
+		return nil, fmt.Errorf("execução ignorada, outra execução está sendo iniciada")
+	}
+	if e.lastJob != nil && e.lastJob.Status() == jobStatusRunning {
+		e.skipped++
+		jobID := e.lastJob.ID
+		e.mu.Unlock()
+		return nil, fmt.Errorf("execução ignorada, job anterior %s ainda em andamento", jobID)
+	}
+	e.starting = true
+	e.runs++
+	e.lastRun = time.Now()
+	e.mu.Unlock()
+
+	job := executeCommandWebhook(e.rule, map[string]string{
+		"event_type": "schedule",
+		"name":       e.name,
+		"trigger":    reason,
+		"timestamp":  time.Now().Format(time.RFC3339),
+	})
+
+	e.mu.Lock()
+	e.lastJob = job
+	e.starting = false
+	e.mu.Unlock()
+	return job, nil
+}
+
+func (e *scheduledCommand) info() scheduleInfo {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	info := scheduleInfo{
+		Name:     e.name,
+		Schedule: e.rule.Schedule,
+		Interval: e.rule.Interval,
+		Runs:     e.runs,
+		Skipped:  e.skipped,
+	}
+	if !e.nextRun.IsZero() {
+		next := e.nextRun
+		info.NextRun = &next
+	}
+	if !e.lastRun.IsZero() {
+		last := e.lastRun
+		info.LastRun = &last
+	}
+	if e.lastJob != nil {
+		info.LastJobID = e.lastJob.ID
+		info.LastState = e.lastJob.Status()
+	}
+	return info
+}
+
+func (s *scheduler) status() []scheduleInfo {
+	infos := make([]scheduleInfo, 0, len(s.entries))
+	for _, entry := range s.entries {
+		infos = append(infos, entry.info())
+	}
+	return infos
+}
+
+// handleSchedulesAPI lista os agendamentos (GET /api/schedules) e permite disparo
+// manual via POST /api/schedules/{nome}/run
+func handleSchedulesAPI(w http.ResponseWriter, r *http.Request) {
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/schedules"), "/")
+	if rest == "" {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+			return
+		}
+		writeJSON(w, http.StatusOK, schedules.status())
+		return
+	}
+
+	name, action, _ := strings.Cut(rest, "/")
+	entry, ok := schedules.byName[name]
+	if !ok {
+		http.Error(w, "Agendamento não encontrado", http.StatusNotFound)
+		return
+	}
+	switch action {
+	case "":
+		if r.Method != http.MethodGet {
+			http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+			return
+		}
+		writeJSON(w, http.StatusOK, entry.info())
+	case "run":
+		if r.Method != http.MethodPost {
+			http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+			return
+		}
+		job, err := entry.trigger("manual")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		log.Printf("Comando agendado '%s' disparado manualmente via API", entry.name)
+		writeJSON(w, http.StatusAccepted, job.info(false))
+	default:
+		http.NotFound(w, r)
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// SyncConfig configura a sincronização de interações entre dispositivos
+type SyncConfig struct {
+	Enabled          bool     `json:"enabled"`
+	ExcludeSelectors []string `json:"exclude_selectors"` // Elementos cujas interações não são sincronizadas
+	Events           []string `json:"events"`            // Padrão: scroll, click, input, navigate
+}
+
+var defaultSyncEvents = []string{"scroll", "click", "input", "navigate"}
+
+// syncState guarda a configuração de sincronização, alterável em tempo de execução
+type syncState struct {
+	mu     sync.Mutex
+	config SyncConfig
+}
+
+// Estado global de sincronização, configurado em main
+var interactionSync = &syncState{}
+
+func (s *syncState) configure(cfg SyncConfig) {
+	if len(cfg.Events) == 0 {
+		cfg.Events = defaultSyncEvents
+	}
+	if cfg.ExcludeSelectors == nil {
+		cfg.ExcludeSelectors = []string{}
+	}
+	s.mu.Lock()
+	s.config = cfg
+	s.mu.Unlock()
+}
+
+func (s *syncState) current() SyncConfig {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.config
+}
+
+func (s *syncState) setEnabled(enabled bool) SyncConfig {
+	s.mu.Lock()
+	s.config.Enabled = enabled
+	cfg := s.config
+	s.mu.Unlock()
+	return cfg
+}
+
+// configMessage é a mensagem enviada aos clientes com o estado da sincronização
+func (s *syncState) configMessage() []byte {
+	cfg := s.current()
+	message, _ := json.Marshal(map[string]interface{}{
+		"type":    "sync-config",
+		"enabled": cfg.Enabled,
+		"exclude": cfg.ExcludeSelectors,
+		"events":  cfg.Events,
+	})
+	return message
+}
+
+func (s *syncState) allows(event string) bool {
+	cfg := s.current()
+	if !cfg.Enabled {
+		return false
+	}
+	for _, e := range cfg.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// syncEvent é uma interação recebida de um cliente. Só os campos conhecidos são reenviados.
+type syncEvent struct {
+	Type     string   `json:"type"`
+	Event    string   `json:"event"`
+	X        *float64 `json:"x,omitempty"`
+	Y        *float64 `json:"y,omitempty"`
+	Selector string   `json:"selector,omitempty"`
+	Value    *string  `json:"value,omitempty"`
+	Checked  *bool    `json:"checked,omitempty"`
+	URL      string   `json:"url,omitempty"`
+}
+
+// sameOriginPath aceita apenas caminhos do próprio site ("/pagina?x=1"), nunca URLs com
+// esquema ou host (javascript:, //outro.site, etc.)
+func sameOriginPath(raw string) bool {
+	if !strings.HasPrefix(raw, "/") || strings.HasPrefix(raw, "//") || strings.HasPrefix(raw, "/\\") || strings.ContainsAny(raw, "\r\n\t") {
+		return false
+	}
+	u, err := url.Parse(raw)
+	return err == nil && u.Scheme == "" && u.Host == "" && u.Opaque == ""
+}
+
+func clampRatio(v *float64) *float64 {
+	if v == nil || math.IsNaN(*v) {
+		zero := 0.0
+		return &zero
+	}
+	r := math.Max(0, math.Min(1, *v))
+	return &r
+}
+
+// sanitize valida a interação e monta a mensagem com apenas os campos do evento
+func (e syncEvent) sanitize() ([]byte, bool) {
+	out := syncEvent{Type: "sync", Event: e.Event}
+	switch e.Event {
+	case "scroll":
+		out.X, out.Y = clampRatio(e.X), clampRatio(e.Y)
+	case "click":
+		if e.Selector == "" {
+			return nil, false
+		}
+		out.Selector = e.Selector
+	case "input":
+		if e.Selector == "" {
+			return nil, false
+		}
+		out.Selector, out.Value, out.Checked = e.Selector, e.Value, e.Checked
+	case "navigate":
+		if !sameOriginPath(e.URL) {
+			return nil, false
+		}
+		out.URL = e.URL
+	default:
+		return nil, false
+	}
+	data, err := json.Marshal(out)
+	return data, err == nil
+}
+
+// relaySyncEvent reenvia uma interação aos outros clientes do mesmo site
+func relaySyncEvent(sender *Client, event string, data []byte) {
+	if !interactionSync.allows(event) {
+		return
+	}
+	var received syncEvent
+	if err := json.Unmarshal(data, &received); err != nil || received.Event != event {
+		return
+	}
+	message, ok := received.sanitize()
+	if !ok {
+		log.Printf("Interação de sincronização inválida descartada (cliente %s, evento %s)", sender.id, event)
+		return
+	}
+	hub.publish(outboundMessage{data: message, target: func(c *Client) bool {
+		return c != sender && c.host == sender.host
+	}})
+}
+
+// handleSyncAPI consulta (GET) ou altera (POST {"enabled": bool}) a sincronização
+func handleSyncAPI(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, interactionSync.current())
+	case http.MethodPost:
+		var req struct {
+			Enabled *bool `json:"enabled"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Enabled == nil {
+			http.Error(w, "Requisição inválida", http.StatusBadRequest)
+			return
+		}
+		cfg := interactionSync.setEnabled(*req.Enabled)
+		log.Printf("Sincronização entre dispositivos %s via API", map[bool]string{true: "ativada", false: "desativada"}[cfg.Enabled])
+		hub.publish(outboundMessage{data: interactionSync.configMessage()})
+		writeJSON(w, http.StatusOK, cfg)
+	default:
+		http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestSyncEventSanitize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string // "" quando a interação deve ser descartada
+	}{
+		{`{"type":"sync","event":"navigate","url":"/docs?x=1"}`, `{"type":"sync","event":"navigate","url":"/docs?x=1"}`},
+		{`{"type":"sync","event":"navigate","url":"javascript:alert(1)"}`, ``},
+		{`{"type":"sync","event":"navigate","url":"//evil.example/"}`, ``},
+		{`{"type":"sync","event":"navigate","url":"/\\evil.example/"}`, ``},
+		{`{"type":"sync","event":"navigate","url":"https://evil.example/"}`, ``},
+		{`{"type":"sync","event":"scroll","x":2,"y":-1,"extra":"<script>"}`, `{"type":"sync","event":"scroll","x":1,"y":0}`},
+		{`{"type":"sync","event":"click","selector":"#a","url":"javascript:x"}`, `{"type":"sync","event":"click","selector":"#a"}`},
+		{`{"type":"sync","event":"click"}`, ``},
+		{`{"type":"sync","event":"input","selector":"#q","value":"oi","checked":false}`, `{"type":"sync","event":"input","selector":"#q","value":"oi","checked":false}`},
+		{`{"type":"sync","event":"eval"}`, ``},
+	}
+	for _, tt := range tests {
+		var event syncEvent
+		if err := json.Unmarshal([]byte(tt.in), &event); err != nil {
+			t.Fatalf("Unmarshal(%s): %v", tt.in, err)
+		}
+		got, ok := event.sanitize()
+		if tt.want == "" {
+			if ok {
+				t.Errorf("sanitize(%s) = %s, esperava descarte", tt.in, got)
+			}
+			continue
+		}
+		if !ok || string(got) != tt.want {
+			t.Errorf("sanitize(%s) = %s, %v; esperava %s", tt.in, got, ok, tt.want)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// Transportes alternativos ao WebSocket para redes que bloqueiam o upgrade.
+// SSE (<prefixo>/events) e long polling (<prefixo>/poll) recebem as mesmas mensagens do hub;
+// o navegador envia as suas via POST <prefixo>/send?client=<id>.
+
+const pollTimeout = 25 * time.Second // Tempo máximo de espera de um long poll sem mensagens
+
+// welcomeMessage informa ao navegador o identificador usado em <prefixo>/send
+func welcomeMessage(c *Client) []byte {
+	message, _ := json.Marshal(map[string]string{"type": "welcome", "client_id": c.id, "transport": c.transport})
+	return message
+}
+
+// handleEventStream mantém uma conexão Server-Sent Events com o navegador
+func handleEventStream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok || r.Method != http.MethodGet {
+		http.Error(w, "Streaming não suportado", http.StatusInternalServerError)
+		return
+	}
+
+	client := newClient(r, "sse")
+	hub.register <- client
+	defer func() { hub.unregister <- client }()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	writeSSEEvent(w, "", welcomeMessage(client))
+	flusher.Flush()
+
+	ticker := time.NewTicker(pingPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case message := <-client.send:
+			writeSSEEvent(w, "", message)
+			flusher.Flush()
+		case <-ticker.C:
+			// Comentário SSE como keepalive para proxies que encerram conexões ociosas
+			fmt.Fprint(w, ": ping\n\n")
+			flusher.Flush()
+		case <-client.done:
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// handlePoll entrega as mensagens pendentes em um array JSON, aguardando até pollTimeout.
+// Sem ?client=<id>, registra um novo cliente e responde com a mensagem de boas-vindas.
+func handlePoll(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-cache")
+
+	id := r.URL.Query().Get("client")
+	if id == "" {
+		client := newClient(r, "polling")
+		hub.register <- client
+		go expirePollingClient(client)
+		writeJSON(w, http.StatusOK, []json.RawMessage{welcomeMessage(client)})
+		return
+	}
+
+	client := hub.find(id)
+	if client == nil || client.transport != "polling" {
+		// O servidor reiniciou ou o cliente expirou: o navegador deve reconectar
+		http.Error(w, "Cliente não encontrado", http.StatusGone)
+		return
+	}
+	client.touch()
+	defer client.touch()
+
+	messages := []json.RawMessage{}
+	timer := time.NewTimer(pollTimeout)
+	defer timer.Stop()
+	select {
+	case message := <-client.send:
+		messages = append(messages, message)
+	case <-timer.C:
+	case <-client.done:
+	case <-r.Context().Done():
+		return
+	}
+	// Junta o que mais estiver na fila na mesma resposta
+drain:
+	for len(messages) > 0 {
+		select {
+		case message := <-client.send:
+			messages = append(messages, message)
+		default:
+			break drain
+		}
+	}
+	writeJSON(w, http.StatusOK, messages)
+}
+
+// touch registra atividade de um cliente de long polling
+func (c *Client) touch() {
+	atomic.StoreInt64(&c.lastSeen, time.Now().UnixNano())
+}
+
+// expirePollingClient remove o cliente quando ele deixa de fazer polls
+func expirePollingClient(c *Client) {
+	c.touch()
+	ticker := time.NewTicker(pollTimeout)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			if time.Since(time.Unix(0, atomic.LoadInt64(&c.lastSeen))) > pongWait {
+				hub.unregister <- c
+				return
+			}
+		}
+	}
+}
+
+// handleClientSend recebe mensagens do navegador nos transportes SSE e long polling
+func handleClientSend(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+		return
+	}
+	client := hub.find(r.URL.Query().Get("client"))
+	if client == nil || client.conn != nil {
+		http.Error(w, "Cliente não encontrado", http.StatusGone)
+		return
+	}
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxMessageSize))
+	if err != nil {
+		http.Error(w, "Mensagem muito grande", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if client.transport == "polling" {
+		client.touch()
+	}
+	client.handleClientMessage(data, r.Host)
+	w.WriteHeader(http.StatusNoContent)
+}